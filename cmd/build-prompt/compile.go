@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bkuri/ppc/internal/compile"
+	"github.com/bkuri/ppc/internal/format"
+)
+
+// runCompile implements `ppc compile --select id1,id2`: an explicit module
+// selection with the same requires expansion, exclusive-group validation
+// and ordering as the mode subcommands, but no implicit base/mode/contract
+func runCompile(args []string, promptsDir string) int {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+
+	selectIDs := fs.String("select", "", "comma-separated module IDs to compile (required)")
+	withBase := fs.Bool("base", false, "also select the base module")
+	mode := fs.String("mode", "", "also select modes/MODE")
+	contract := fs.String("contract", "", "also select contracts/CONTRACT")
+	var varsFiles listFlag
+	fs.Var(&varsFiles, "vars", "YAML or .env file with variable definitions (repeatable; later files are deep-merged over earlier ones)")
+	varsEnv := fs.String("vars-env", "", "read variables from environment variables with this prefix (e.g. PPC_; __ separates nested keys)")
+	cliVars := make(varsFlag)
+	fs.Var(&cliVars, "var", "key=value variable (repeatable)")
+	outPath := fs.String("out", "", "write output to file")
+	explain := fs.Bool("explain", false, "explain resolution steps to stderr")
+	withHash := fs.Bool("hash", false, "prepend prompt-id hash header")
+	withProvenance := fs.Bool("provenance", false, "prepend compiled-from header with module sources and hashes")
+	strictVars := fs.Bool("strict-vars", false, "fail when any {{variable}} is left unresolved")
+	maxTokens := fs.Int("max-tokens", 0, "fail when the prompt exceeds this many tokens (0=use lint.max_tokens, -1=disabled)")
+	outFormat := fs.String("format", format.Markdown, "output format: markdown|json|yaml|openai-messages|anthropic-system")
+	sourcemapPath := fs.String("sourcemap", "", "write a JSON source map of output lines to module files")
+	proDir := promptsFlag(fs, promptsDir, "prompts directory (repeatable; later roots may override modules)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage:
+  ppc compile --select id1,id2 [flags]
+
+Compiles exactly the selected modules and whatever they require.
+base, a mode and a contract are only added with --base, --mode, --contract.
+
+flags:`)
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	ids := parseCSV(*selectIDs)
+	if len(ids) == 0 {
+		fmt.Fprintln(os.Stderr, "compile: --select is required")
+		fs.Usage()
+		return 2
+	}
+	if err := format.Validate(*outFormat); err != nil {
+		dief("compile: %v", err)
+	}
+	if err := checkSourcemap(*sourcemapPath, *outFormat); err != nil {
+		dief("compile: %v", err)
+	}
+
+	return emitPrompt(compile.CompileOptions{
+		Select:      ids,
+		IncludeBase: *withBase,
+		Mode:        *mode,
+		Contract:    *contract,
+		PromptsDir:  *proDir,
+		VarsFiles:   varsFiles,
+		VarsEnv:     *varsEnv,
+		Vars:        cliVars,
+		MaxTokens:   *maxTokens,
+		StrictVars:  *strictVars,
+	}, outputOptions{
+		Out:        *outPath,
+		Hash:       *withHash,
+		Provenance: *withProvenance,
+		Explain:    *explain,
+		Format:     *outFormat,
+		Sourcemap:  *sourcemapPath,
+	})
+}
@@ -21,13 +21,21 @@ type ResolvedConfig struct {
 	Traits     []string
 	Guardrails []string
 	Policies   []string
-	Vars       map[string]any
-	VarsFile   string
+	// Vars holds the profile's vars; CLIVars those set by flags, which
+	// take precedence
+	Vars    map[string]any
+	CLIVars map[string]any
+	// VarsFiles lists the profile's vars_file, then each --vars file
+	VarsFiles  []string
 	PromptsDir string
+	Out        string
+	Hash       bool
 }
 
-func NewResolvedConfigFromProfile(profileName string) (*ResolvedConfig, error) {
-	profile, err := profilepkg.LoadProfile(profileName)
+// NewResolvedConfigFromProfile loads a profile by name or path. Names are
+// searched along the profile search path for promptsDir.
+func NewResolvedConfigFromProfile(profileRef, promptsDir string) (*ResolvedConfig, error) {
+	profile, _, err := profilepkg.Resolve(profileRef, promptsDir)
 	if err != nil {
 		return nil, err
 	}
@@ -37,21 +45,28 @@ func NewResolvedConfigFromProfile(profileName string) (*ResolvedConfig, error) {
 		Contract:   profile.Contract,
 		Revisions:  -1,
 		Traits:     profile.Traits,
-		Guardrails: []string{},
-		Policies:   []string{},
+		Guardrails: append([]string{}, profile.Guardrails...),
+		Policies:   append([]string{}, profile.Policies...),
 		Vars:       map[string]any{},
-		VarsFile:   "",
-		PromptsDir: "",
+		CLIVars:    map[string]any{},
+		PromptsDir: profile.PromptsDir,
+		Out:        profile.Out,
+		Hash:       profile.Hash != nil && *profile.Hash,
 	}
 
-	if profile.Revisions != nil {
-		cfg.Revisions = *profile.Revisions
+	if profile.VarsFile != "" {
+		cfg.VarsFiles = []string{profile.VarsFile}
 	}
-
 	if profile.Vars != nil {
 		cfg.Vars = profile.Vars
 	}
 
+	if profile.Revisions != nil {
+		cfg.Revisions = *profile.Revisions
+		cfg.Policies = appendPolicy(cfg.Policies, "revisions")
+		cfg.Vars["revisions"] = *profile.Revisions
+	}
+
 	return &cfg, nil
 }
 
@@ -64,71 +79,126 @@ func NewResolvedConfigFromDefaults(mode string, contract string) ResolvedConfig
 		Guardrails: []string{},
 		Policies:   []string{},
 		Vars:       map[string]any{},
-		VarsFile:   "",
+		CLIVars:    map[string]any{},
 		PromptsDir: "",
 	}
 }
 
-func (c *ResolvedConfig) ApplyCLIOverrides(conservative, creative, terse, verbose *bool, revisions *int, contract, varsFile, guardrails, policies *string) (*ResolvedConfig, error) {
+// ApplyCLIOverrides layers CLI flags over the config. traits holds names
+// or module IDs from --trait, --traits and the trait alias flags; they are
+// validated against modByID, the modules loaded from the prompts directory.
+func (c *ResolvedConfig) ApplyCLIOverrides(traits []string, revisions *int, contract *string, varsFiles []string, guardrails, policies *string, modByID map[string]*model.Module) (*ResolvedConfig, error) {
 	cfg := *c
-
-	if conservative != nil && *conservative {
-		cfg.Traits = append(cfg.Traits, "traits/conservative")
-	}
-	if creative != nil && *creative {
-		cfg.Traits = append(cfg.Traits, "traits/creative")
+	cfg.CLIVars = map[string]any{}
+	for k, v := range c.CLIVars {
+		cfg.CLIVars[k] = v
 	}
-	if terse != nil && *terse {
-		cfg.Traits = append(cfg.Traits, "traits/terse")
-	}
-	if verbose != nil && *verbose {
-		cfg.Traits = append(cfg.Traits, "traits/verbose")
+
+	for _, t := range traits {
+		id := traitID(t)
+		if !resolver.Contains(cfg.Traits, id) {
+			cfg.Traits = append(cfg.Traits, id)
+		}
 	}
 	if revisions != nil && *revisions >= 0 {
 		cfg.Revisions = *revisions
-		cfg.Policies = append(cfg.Policies, "revisions")
-		cfg.Vars["revisions"] = *revisions
+		cfg.Policies = appendPolicy(cfg.Policies, "revisions")
+		cfg.CLIVars["revisions"] = *revisions
 	}
 	if contract != nil && *contract != "" {
 		cfg.Contract = *contract
 	}
-	if varsFile != nil && *varsFile != "" {
-		cfg.VarsFile = *varsFile
-	}
+	cfg.VarsFiles = append(append([]string{}, cfg.VarsFiles...), varsFiles...)
 	if guardrails != nil && *guardrails != "" {
 		cfg.Guardrails = parseGuardrails(*guardrails, cfg.PromptsDir)
 	}
 	if policies != nil && *policies != "" {
-		cfg.Policies = append(cfg.Policies, parseCSV(*policies)...)
+		for _, p := range parseCSV(*policies) {
+			cfg.Policies = appendPolicy(cfg.Policies, p)
+		}
 	}
 
-	return &cfg, validateExclusiveGroups(cfg.Traits, cfg.PromptsDir)
+	return &cfg, validateTraits(cfg.Traits, modByID, cfg.PromptsDir)
+}
+
+// appendPolicy adds a policy unless it is already selected
+func appendPolicy(policies []string, name string) []string {
+	if resolver.Contains(policies, name) {
+		return policies
+	}
+	return append(policies, name)
+}
+
+// traitID maps a trait name to its module ID (terse -> traits/terse)
+func traitID(name string) string {
+	if strings.HasPrefix(name, "traits/") {
+		return name
+	}
+	return "traits/" + name
+}
+
+// discoverTraits returns the names of all traits/* modules, sorted
+func discoverTraits(modByID map[string]*model.Module) []string {
+	var out []string
+	for id := range modByID {
+		if name, ok := strings.CutPrefix(id, "traits/"); ok {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
 }
 
 // parseGuardrails parses a comma-separated guardrail flag value.
 // If value is "all", auto-discovers all guardrail modules in the prompts directory.
 func parseGuardrails(value string, promptsDir string) []string {
-	if value == "all" {
-		return discoverAllGuardrails(promptsDir)
+	return expandGuardrails(parseCSV(value), promptsDir)
+}
+
+// expandGuardrails replaces each "all" entry with every guardrail module in
+// the prompts directory and drops duplicates. "all" may sit anywhere in the
+// list, since a profile's guardrails are merged with those it extends.
+func expandGuardrails(names []string, promptsDir string) []string {
+	if !resolver.Contains(names, "all") {
+		return names
+	}
+	var out []string
+	for _, name := range names {
+		expanded := []string{name}
+		if name == "all" {
+			expanded = discoverAllGuardrails(promptsDir)
+		}
+		for _, g := range expanded {
+			if !resolver.Contains(out, g) {
+				out = append(out, g)
+			}
+		}
 	}
-	return parseCSV(value)
+	return out
 }
 
 func discoverAllGuardrails(promptsDir string) []string {
-	entries, err := os.ReadDir(filepath.Join(promptsDir, "guardrails"))
-	if err != nil {
-		return nil
-	}
+	seen := map[string]bool{}
 	var out []string
-	for _, e := range entries {
-		if e.IsDir() {
+	for _, root := range loader.Roots(promptsDir) {
+		entries, err := os.ReadDir(filepath.Join(root, "guardrails"))
+		if err != nil {
 			continue
 		}
-		name := e.Name()
-		if !strings.HasSuffix(strings.ToLower(name), ".md") {
-			continue
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			name := e.Name()
+			if !strings.HasSuffix(strings.ToLower(name), ".md") {
+				continue
+			}
+			name = strings.TrimSuffix(name, ".md")
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
 		}
-		out = append(out, strings.TrimSuffix(name, ".md"))
 	}
 	sort.Strings(out)
 	return out
@@ -136,23 +206,21 @@ func discoverAllGuardrails(promptsDir string) []string {
 
 func (c *ResolvedConfig) ToCompileOptions() compilepkg.CompileOptions {
 	return compilepkg.CompileOptions{
-		Mode:       c.Mode,
-		Contract:   c.Contract,
-		Traits:     c.Traits,
-		Guardrails: c.Guardrails,
-		Policies:   c.Policies,
-		PromptsDir: c.PromptsDir,
-		VarsFile:   c.VarsFile,
-		Vars:       c.Vars,
+		Mode:        c.Mode,
+		Contract:    c.Contract,
+		Traits:      c.Traits,
+		Guardrails:  c.Guardrails,
+		Policies:    c.Policies,
+		PromptsDir:  c.PromptsDir,
+		VarsFiles:   c.VarsFiles,
+		ProfileVars: c.Vars,
+		Vars:        c.CLIVars,
 	}
 }
 
-func validateExclusiveGroups(traits []string, promptsDir string) error {
-	modByID, errIf := loader.LoadModules(promptsDir)
-	if errIf != nil {
-		return fmt.Errorf("loading modules for exclusive group validation: %w", errIf)
-	}
-
+// validateTraits checks that every trait exists in modByID and that the
+// selected traits do not conflict on an exclusive group in promptsDir's rules
+func validateTraits(traits []string, modByID map[string]*model.Module, promptsDir string) error {
 	rules, errIf := loader.LoadRules(promptsDir)
 	if errIf != nil {
 		return fmt.Errorf("loading rules for exclusive group validation: %w", errIf)
@@ -162,7 +230,8 @@ func validateExclusiveGroups(traits []string, promptsDir string) error {
 	for _, t := range traits {
 		m, ok := modByID[t]
 		if !ok {
-			continue
+			return fmt.Errorf("unknown trait %q (available: %s)",
+				strings.TrimPrefix(t, "traits/"), strings.Join(discoverTraits(modByID), ", "))
 		}
 		mods = append(mods, m)
 	}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bkuri/ppc/internal/compile"
+	diffpkg "github.com/bkuri/ppc/internal/diff"
+	"github.com/bkuri/ppc/internal/gitref"
+)
+
+// diffSide is one side of `ppc diff`: mode subcommand arguments, compiled
+// in the working tree or at a git ref
+type diffSide struct {
+	Args []string
+	Ref  string
+}
+
+func (s diffSide) label() string {
+	quoted := make([]string, len(s.Args))
+	for i, a := range s.Args {
+		quoted[i] = a
+		if a == "" || strings.ContainsAny(a, " \t\n'\"\\") {
+			quoted[i] = strconv.Quote(a)
+		}
+	}
+	spec := strings.Join(quoted, " ")
+	if s.Ref == "" {
+		return spec
+	}
+	return spec + " (" + s.Ref + ")"
+}
+
+// diffSideReport describes a compiled side in `ppc diff --json`
+type diffSideReport struct {
+	Label string   `json:"label"`
+	Ref   string   `json:"ref,omitempty"`
+	Hash  string   `json:"hash"`
+	Order []string `json:"order"`
+}
+
+// diffReport is the output of `ppc diff --json`
+type diffReport struct {
+	Left    diffSideReport  `json:"left"`
+	Right   diffSideReport  `json:"right"`
+	Changed bool            `json:"changed"`
+	Modules diffpkg.Summary `json:"modules"`
+	Diff    string          `json:"diff"`
+}
+
+// runDiff implements `ppc diff`. Exit code: 0=identical, 1=different,
+// 2=error
+func runDiff(args []string, promptsDir string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var refs, profiles listFlag
+	fs.Var(&refs, "ref", "compile at this git ref (repeatable: first ref is the left side)")
+	fs.Var(&profiles, "profile", "add --profile to the sides (repeatable: first profile is the left side)")
+	jsonOut := fs.Bool("json", false, "output machine-readable JSON")
+	proDir := promptsFlag(fs, promptsDir, "default prompts directory for both sides")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage:
+  ppc diff [flags] ["<side>" ["<side>"]]
+
+Compiles two sides and prints a unified diff of the prompts plus the
+modules added, removed and reordered. A side is a mode subcommand with its
+flags, e.g. "build --terse"; one side is used for both. With two --profile
+or --ref flags the first applies to the left side and the second to the
+right; a single --ref compares that ref with the working tree, and a single
+--profile applies to both sides. With a profile the mode may be left out.
+
+exit codes: 0 identical, 1 different, 2 error
+
+examples:
+  ppc diff --profile ship --profile ship-next
+  ppc diff "build --conservative" "build --creative"
+  ppc diff --ref main --ref HEAD --profile ship
+
+flags:`)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	left, right, err := diffSides(fs.Args(), profiles, refs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	leftOut, leftMeta, err := compileSide(left, *proDir)
+	if err != nil {
+		dief("diff: %s: %v", left.label(), err)
+	}
+	rightOut, rightMeta, err := compileSide(right, *proDir)
+	if err != nil {
+		dief("diff: %s: %v", right.label(), err)
+	}
+
+	text := diffpkg.Unified(left.label(), right.label(), leftOut, rightOut)
+	summary := diffpkg.Modules(leftMeta.Order, rightMeta.Order)
+	changed := text != "" || !summary.Empty()
+
+	if *jsonOut {
+		report := diffReport{
+			Left:    diffSideReport{Label: left.label(), Ref: left.Ref, Hash: leftMeta.Hash, Order: leftMeta.Order},
+			Right:   diffSideReport{Label: right.label(), Ref: right.Ref, Hash: rightMeta.Hash, Order: rightMeta.Order},
+			Changed: changed,
+			Modules: summary,
+			Diff:    text,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			dief("JSON encode error: %v", err)
+		}
+	} else {
+		printDiff(text, summary)
+	}
+
+	if changed {
+		return 1
+	}
+	return 0
+}
+
+// diffSides pairs up the side arguments, --profile and --ref values
+func diffSides(sides, profiles, refs []string) (diffSide, diffSide, error) {
+	var left, right diffSide
+	if len(sides) > 2 || len(profiles) > 2 || len(refs) > 2 {
+		return left, right, fmt.Errorf("expected at most two sides, profiles and refs")
+	}
+	specs := make([][]string, len(sides))
+	for i, spec := range sides {
+		args, err := splitArgs(spec)
+		if err != nil {
+			return left, right, fmt.Errorf("side %q: %v", spec, err)
+		}
+		specs[i] = args
+	}
+	switch len(specs) {
+	case 1:
+		left.Args, right.Args = specs[0], specs[0]
+	case 2:
+		left.Args, right.Args = specs[0], specs[1]
+	}
+	switch len(profiles) {
+	case 1:
+		left.Args = slices.Concat(left.Args, []string{"--profile", profiles[0]})
+		right.Args = slices.Concat(right.Args, []string{"--profile", profiles[0]})
+	case 2:
+		left.Args = slices.Concat(left.Args, []string{"--profile", profiles[0]})
+		right.Args = slices.Concat(right.Args, []string{"--profile", profiles[1]})
+	}
+	switch len(refs) {
+	case 1:
+		left.Ref = refs[0]
+	case 2:
+		left.Ref, right.Ref = refs[0], refs[1]
+	}
+	if left.Ref == right.Ref && slices.Equal(left.Args, right.Args) {
+		return left, right, fmt.Errorf("nothing to compare: give two sides, two profiles or a git ref")
+	}
+	return left, right, nil
+}
+
+func printDiff(text string, s diffpkg.Summary) {
+	if text == "" && s.Empty() {
+		fmt.Println("no differences")
+		return
+	}
+	if s.Empty() {
+		fmt.Println("modules: unchanged")
+	} else {
+		fmt.Println("modules:")
+		for _, l := range []struct {
+			name string
+			ids  []string
+		}{
+			{"added", s.Added},
+			{"removed", s.Removed},
+			{"reordered", s.Reordered},
+		} {
+			if len(l.ids) > 0 {
+				fmt.Printf("  %s: %s\n", l.name, strings.Join(l.ids, ", "))
+			}
+		}
+	}
+	if text != "" {
+		fmt.Println()
+		fmt.Print(text)
+	}
+}
+
+// compileSide compiles a side. At a git ref, the side is compiled from an
+// exported copy of the repository, with relative paths resolved against the
+// directory matching the current one, so they refer to that revision.
+func compileSide(side diffSide, promptsDir string) (string, compile.CompileMeta, error) {
+	dir := ""
+	if side.Ref != "" {
+		tmp, err := os.MkdirTemp("", "ppc-diff-")
+		if err != nil {
+			return "", compile.CompileMeta{}, err
+		}
+		defer os.RemoveAll(tmp)
+		dir, err = gitref.Export(".", side.Ref, tmp)
+		if err != nil {
+			return "", compile.CompileMeta{}, err
+		}
+	}
+
+	args := side.Args
+	mode := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+	}
+	cmd, err := parseModeArgs(mode, "", args, promptsDir, dir, flag.ContinueOnError)
+	if err != nil {
+		return "", compile.CompileMeta{}, err
+	}
+	if mode == "" && !cmd.visited["profile"] {
+		return "", compile.CompileMeta{}, fmt.Errorf("expected a mode or --profile")
+	}
+	for _, name := range []string{"out", "sourcemap", "watch"} {
+		if cmd.visited[name] {
+			return "", compile.CompileMeta{}, fmt.Errorf("--%s is not supported in a diff side", name)
+		}
+	}
+
+	opts, o, _, err := cmd.resolve()
+	if err != nil {
+		return "", compile.CompileMeta{}, err
+	}
+	return renderPrompt(opts, o)
+}
+
+// splitArgs splits a side into arguments like a POSIX shell: whitespace
+// separates arguments, single quotes keep their content literally, and a
+// backslash escapes the next character (within double quotes, only " and \)
+func splitArgs(spec string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, c := range spec {
+		switch {
+		case escaped:
+			if quote == '"' && c != '"' && c != '\\' {
+				cur.WriteRune('\\')
+			}
+			cur.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case unicode.IsSpace(c):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or trailing backslash")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -4,14 +4,21 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/bkuri/ppc/internal/compile"
 	"github.com/bkuri/ppc/internal/doctor"
+	"github.com/bkuri/ppc/internal/format"
 	"github.com/bkuri/ppc/internal/lint"
 	"github.com/bkuri/ppc/internal/loader"
+	"github.com/bkuri/ppc/internal/model"
+	profilepkg "github.com/bkuri/ppc/internal/profile"
+	"github.com/bkuri/ppc/internal/resolver"
+	"github.com/bkuri/ppc/internal/sourcemap"
 )
 
 // dief prints error to stderr and exits
@@ -20,6 +27,47 @@ func dief(format string, args ...any) {
 	os.Exit(2)
 }
 
+// listFlag collects a repeatable string flag
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(raw string) error {
+	*l = append(*l, raw)
+	return nil
+}
+
+// rootsFlag collects repeatable --prompts roots in precedence order,
+// joined with os.PathListSeparator. The first value replaces the default.
+type rootsFlag struct {
+	dst *string
+	set bool
+}
+
+func (r *rootsFlag) String() string {
+	if r == nil || r.dst == nil {
+		return ""
+	}
+	return *r.dst
+}
+
+func (r *rootsFlag) Set(raw string) error {
+	if !r.set {
+		r.set = true
+		*r.dst = raw
+		return nil
+	}
+	*r.dst = loader.JoinRoots([]string{*r.dst, raw})
+	return nil
+}
+
+// promptsFlag defines the repeatable --prompts flag on fs
+func promptsFlag(fs *flag.FlagSet, def, usage string) *string {
+	dst := def
+	fs.Var(&rootsFlag{dst: &dst}, "prompts", usage)
+	return &dst
+}
+
 type varsFlag map[string]any
 
 func (v varsFlag) String() string { return "" }
@@ -69,99 +117,416 @@ func explainOutput(meta compile.CompileMeta) {
 	for _, id := range meta.Order {
 		fmt.Fprintf(os.Stderr, "  - %s\n", id)
 	}
+
+	fmt.Fprintf(os.Stderr, "Tokens (%s):\n", meta.Encoding)
+	for _, s := range meta.Segments {
+		fmt.Fprintf(os.Stderr, "  - %s: %d\n", s.ID, s.Tokens)
+	}
+	fmt.Fprintf(os.Stderr, "  total: %d\n", meta.Tokens)
+
+	var versioned []compile.ModuleSource
+	for _, s := range meta.Sources {
+		if s.Version != "" {
+			versioned = append(versioned, s)
+		}
+	}
+	if len(versioned) > 0 {
+		fmt.Fprintln(os.Stderr, "Versions:")
+		for _, s := range versioned {
+			fmt.Fprintf(os.Stderr, "  - %s: %s\n", s.ID, s.Version)
+		}
+	}
+
+	if len(meta.VarSources) > 0 {
+		fmt.Fprintln(os.Stderr, "Variables:")
+		for _, v := range meta.VarSources {
+			fmt.Fprintf(os.Stderr, "  - %s: %s\n", v.Name, v.Source)
+		}
+	}
+
+	if len(meta.Optional) > 0 {
+		fmt.Fprintln(os.Stderr, "Optional dependencies:")
+		for _, d := range meta.Optional {
+			fmt.Fprintln(os.Stderr, "  - "+describeOptional(d))
+		}
+	}
+
+	// With layered prompts roots, show where each module came from
+	roots := map[string]bool{}
+	for _, s := range meta.Sources {
+		roots[s.Root] = true
+	}
+	if len(roots) > 1 {
+		fmt.Fprintln(os.Stderr, "Sources:")
+		for _, s := range meta.Sources {
+			fmt.Fprintf(os.Stderr, "  - %s: %s (%s)\n", s.ID, s.Root, s.Path)
+		}
+	}
+}
+
+// describeOptional formats a suggests or requires_any edge for --explain
+func describeOptional(d compile.OptionalDep) string {
+	targets := strings.Join(d.Targets, ", ")
+	if d.Kind == resolver.RequiresAny {
+		return fmt.Sprintf("%s requires_any %s: satisfied by %s", d.From, targets, d.Match)
+	}
+	switch {
+	case d.Match != "":
+		return fmt.Sprintf("%s suggests %s: included", d.From, targets)
+	case d.Missing:
+		return fmt.Sprintf("%s suggests %s: not present", d.From, targets)
+	default:
+		return fmt.Sprintf("%s suggests %s: not selected", d.From, targets)
+	}
+}
+
+// runMode compiles a mode subcommand. modByID holds the modules already
+// loaded from dir, which a single compile from dir reuses.
+func runMode(mode, desc string, args []string, promptsDir, dir string, modByID map[string]*model.Module) int {
+	cmd, _ := parseModeArgs(mode, desc, args, promptsDir, "", flag.ExitOnError)
+	if cmd.watch {
+		return watchPrompt(cmd.resolve)
+	}
+	cmd.modules, cmd.modulesDir = modByID, dir
+	opts, o, _, err := cmd.resolve()
+	if err != nil {
+		dief("%v", err)
+	}
+	return emitPrompt(opts, o)
 }
 
-var modeDescription = map[string]string{
-	"explore": "Explore mode generates a prompt for exploration tasks.",
-	"build":   "Build mode generates a prompt for building/implementing features.",
-	"ship":    "Ship mode generates a prompt for release/deployment tasks.",
+// inDir resolves each relative path of the path list p against dir
+func inDir(dir, p string) string {
+	if dir == "" || p == "" {
+		return p
+	}
+	paths := filepath.SplitList(p)
+	for i, q := range paths {
+		if !filepath.IsAbs(q) {
+			paths[i] = filepath.Join(dir, q)
+		}
+	}
+	return strings.Join(paths, string(os.PathListSeparator))
 }
 
-func runMode(mode string, args []string, promptsDir string) int {
-	fs := flag.NewFlagSet(mode, flag.ExitOnError)
+// modeCommand is a parsed mode subcommand
+type modeCommand struct {
+	// resolve builds the compile options from the profile and flags. It
+	// also returns the files that affect the result, for --watch.
+	resolve func() (compile.CompileOptions, outputOptions, []string, error)
+	// promptsDir returns the prompts roots resolve compiles from
+	promptsDir func() (string, error)
+	visited    map[string]bool
+	watch      bool
+	// modules, when set, were loaded from modulesDir and are reused by
+	// resolve instead of loading that directory again
+	modules    map[string]*model.Module
+	modulesDir string
+}
 
-	profile := fs.String("profile", "", "load preset configuration (e.g., ship)")
-	conservative := fs.Bool("conservative", false, "include traits/conservative")
-	creative := fs.Bool("creative", false, "include traits/creative")
-	terse := fs.Bool("terse", false, "include traits/terse")
-	verbose := fs.Bool("verbose", false, "include traits/verbose")
+// parseModeArgs parses the flags of a mode subcommand. Relative paths in
+// args and promptsDir resolve against dir, or the working directory if
+// dir is empty.
+func parseModeArgs(mode, desc string, args []string, promptsDir, dir string, handling flag.ErrorHandling) (*modeCommand, error) {
+	fs := flag.NewFlagSet(mode, handling)
+	if handling != flag.ExitOnError {
+		fs.SetOutput(io.Discard)
+	}
+
+	profile := fs.String("profile", "", "load preset configuration by name (e.g., ship) or path (e.g., ./team.yml)")
+	var traitFlags listFlag
+	fs.Var(&traitFlags, "trait", "include a trait module by name (repeatable, e.g. --trait terse)")
+	traitsCSV := fs.String("traits", "", "comma-separated trait names (e.g., conservative,terse)")
+	conservative := fs.Bool("conservative", false, "alias for --trait conservative")
+	creative := fs.Bool("creative", false, "alias for --trait creative")
+	terse := fs.Bool("terse", false, "alias for --trait terse")
+	verbose := fs.Bool("verbose", false, "alias for --trait verbose")
 	revisions := fs.Int("revisions", -1, "revision budget (enables policies/revisions)")
 	contract := fs.String("contract", "markdown", "contract module (code|markdown)")
 	guardrails := fs.String("guardrails", "", "comma-separated guardrail modules (e.g., tdd,snake_case; use \"all\" for all guardrails)")
 	policies := fs.String("policies", "", "comma-separated policy modules (e.g., revisions,self_score)")
-	varsFile := fs.String("vars", "", "path to YAML file with variable definitions")
+	var varsFiles listFlag
+	fs.Var(&varsFiles, "vars", "YAML or .env file with variable definitions (repeatable; later files are deep-merged over earlier ones)")
+	varsEnv := fs.String("vars-env", "", "read variables from environment variables with this prefix (e.g. PPC_; __ separates nested keys)")
 	cliVars := make(varsFlag)
 	fs.Var(&cliVars, "var", "key=value variable (repeatable, e.g. --var name=foo --var count=3)")
 	outPath := fs.String("out", "", "write output to file")
 	explain := fs.Bool("explain", false, "explain resolution steps to stderr")
 	withHash := fs.Bool("hash", false, "prepend prompt-id hash header")
-	proDir := fs.String("prompts", promptsDir, "prompts directory")
+	withProvenance := fs.Bool("provenance", false, "prepend compiled-from header with module sources and hashes")
+	strictVars := fs.Bool("strict-vars", false, "fail when any {{variable}} is left unresolved")
+	maxTokens := fs.Int("max-tokens", 0, "fail when the prompt exceeds this many tokens (0=use lint.max_tokens, -1=disabled)")
+	outFormat := fs.String("format", format.Markdown, "output format: markdown|json|yaml|openai-messages|anthropic-system")
+	sourcemapPath := fs.String("sourcemap", "", "write a JSON source map of output lines to module files")
+	proDir := promptsFlag(fs, promptsDir, "prompts directory (repeatable; later roots may override modules)")
+	watchMode := fs.Bool("watch", false, "recompile when modules, rules, profile or vars change")
 
 	fs.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage:\n  ppc %s [flags]\n\n%s\n\nflags:\n", mode, modeDescription[mode])
+		fmt.Fprintf(os.Stderr, "usage:\n  ppc %s [flags]\n\n%s\n\nflags:\n", mode, modeDescription(mode, desc))
 		fs.PrintDefaults()
 	}
 
-	fs.Parse(args)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	visited := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
 
-	cfg := &ResolvedConfig{}
-	if *profile != "" {
-		profCfg, err := NewResolvedConfigFromProfile(*profile)
+	*proDir = inDir(dir, *proDir)
+	for i, f := range varsFiles {
+		varsFiles[i] = inDir(dir, f)
+	}
+
+	cmd := &modeCommand{visited: visited, watch: *watchMode}
+
+	// baseConfig loads the profile, or the defaults without one, and
+	// settles the prompts roots: --prompts, else the profile's, else the
+	// default. It also returns the profile chain's names and files.
+	baseConfig := func() (*ResolvedConfig, []string, []string, error) {
+		cfg := &ResolvedConfig{}
+		var profiles, files []string
+		if *profile != "" {
+			ref := *profile
+			if path, err := profilepkg.FindIn(dir, ref, *proDir); err == nil {
+				ref = path
+				files, _ = profilepkg.ChainFiles(path)
+				profiles = profilepkg.ChainNames(files)
+			}
+			profCfg, err := NewResolvedConfigFromProfile(ref, *proDir)
+			if err != nil {
+				return nil, nil, files, fmt.Errorf("profile error: %w", err)
+			}
+			cfg = profCfg
+		} else {
+			defaults := NewResolvedConfigFromDefaults(mode, *contract)
+			cfg = &defaults
+		}
+
+		// Set PromptsDir before ApplyCLIOverrides so guardrails discovery works
+		if visited["prompts"] || cfg.PromptsDir == "" {
+			cfg.PromptsDir = *proDir
+		}
+		return cfg, profiles, files, nil
+	}
+
+	cmd.promptsDir = func() (string, error) {
+		cfg, _, _, err := baseConfig()
 		if err != nil {
-			dief("profile error: %v", err)
+			return "", err
 		}
-		cfg = profCfg
-	} else {
-		defaults := NewResolvedConfigFromDefaults(mode, *contract)
-		cfg = &defaults
+		return cfg.PromptsDir, nil
 	}
 
-	// Set PromptsDir before ApplyCLIOverrides so guardrails discovery works
-	cfg.PromptsDir = *proDir
+	cmd.resolve = func() (compile.CompileOptions, outputOptions, []string, error) {
+		watched := append(loader.Roots(*proDir), varsFiles...)
+
+		cfg, profiles, files, err := baseConfig()
+		watched = append(watched, files...)
+		if err != nil {
+			return compile.CompileOptions{}, outputOptions{}, watched, err
+		}
+		cfg.Guardrails = expandGuardrails(cfg.Guardrails, cfg.PromptsDir)
+
+		// Only an explicit --contract overrides the profile's contract
+		contract := contract
+		if !visited["contract"] {
+			contract = nil
+		}
+
+		var traits []string
+		for _, alias := range []struct {
+			on   bool
+			name string
+		}{
+			{*conservative, "conservative"},
+			{*creative, "creative"},
+			{*terse, "terse"},
+			{*verbose, "verbose"},
+		} {
+			if alias.on {
+				traits = append(traits, alias.name)
+			}
+		}
+		traits = append(traits, traitFlags...)
+		traits = append(traits, parseCSV(*traitsCSV)...)
 
-	cfg, err := cfg.ApplyCLIOverrides(conservative, creative, terse, verbose, revisions, contract, varsFile, guardrails, policies)
+		if err := format.Validate(*outFormat); err != nil {
+			return compile.CompileOptions{}, outputOptions{}, watched, err
+		}
+		if err := checkSourcemap(*sourcemapPath, *outFormat); err != nil {
+			return compile.CompileOptions{}, outputOptions{}, watched, err
+		}
+
+		modByID := cmd.modules
+		if modByID == nil || cfg.PromptsDir != cmd.modulesDir {
+			modByID, err = loader.LoadModules(cfg.PromptsDir)
+			if err != nil {
+				return compile.CompileOptions{}, outputOptions{}, watched, err
+			}
+		}
+
+		cfg, err = cfg.ApplyCLIOverrides(traits, revisions, contract, varsFiles, guardrails, policies, modByID)
+		if err != nil {
+			return compile.CompileOptions{}, outputOptions{}, watched, fmt.Errorf("merge error: %w", err)
+		}
+		watched = append(watched, loader.Roots(cfg.PromptsDir)...)
+		watched = append(watched, cfg.VarsFiles...)
+
+		if visited["out"] {
+			cfg.Out = *outPath
+		}
+		if visited["hash"] {
+			cfg.Hash = *withHash
+		}
+
+		for k, v := range cliVars {
+			cfg.CLIVars[k] = v
+		}
+
+		opts := cfg.ToCompileOptions()
+		opts.MaxTokens = *maxTokens
+		opts.Profiles = profiles
+		opts.StrictVars = *strictVars
+		opts.VarsEnv = *varsEnv
+		opts.Modules = modByID
+		return opts, outputOptions{
+			Out:        cfg.Out,
+			Hash:       cfg.Hash,
+			Provenance: *withProvenance,
+			Explain:    *explain,
+			Profile:    *profile,
+			Format:     *outFormat,
+			Sourcemap:  *sourcemapPath,
+		}, watched, nil
+	}
+
+	return cmd, nil
+}
+
+// outputOptions controls how a compiled prompt is decorated and written
+type outputOptions struct {
+	Out        string
+	Hash       bool
+	Provenance bool
+	Explain    bool
+	Profile    string
+	Format     string
+	Sourcemap  string
+}
+
+// emitPrompt compiles opts and writes the prompt to stdout (and Out, if set)
+func emitPrompt(opts compile.CompileOptions, o outputOptions) int {
+	out, meta, err := renderPrompt(opts, o)
 	if err != nil {
-		dief("merge error: %v", err)
+		dief("compile error: %v", err)
 	}
 
-	for k, v := range cliVars {
-		cfg.Vars[k] = v
+	if o.Out != "" {
+		if err := os.WriteFile(o.Out, []byte(out), 0o644); err != nil {
+			dief("failed to write %s: %v", o.Out, err)
+		}
+	}
+	if err := writeSourcemap(o, out, meta); err != nil {
+		dief("sourcemap: %v", err)
 	}
+	fmt.Print(out)
+	return 0
+}
 
-	opts := cfg.ToCompileOptions()
+// checkSourcemap rejects --sourcemap for formats other than markdown,
+// whose lines do not correspond to the prompt's
+func checkSourcemap(path, outFormat string) error {
+	if path != "" && outFormat != format.Markdown {
+		return fmt.Errorf("--sourcemap requires --format %s", format.Markdown)
+	}
+	return nil
+}
 
+// writeSourcemap writes the source map of out to o.Sourcemap, if set
+func writeSourcemap(o outputOptions, out string, meta compile.CompileMeta) error {
+	if o.Sourcemap == "" {
+		return nil
+	}
+	m, err := sourcemap.Build(out, meta)
+	if err != nil {
+		return err
+	}
+	m.File = o.Out
+	b, err := m.Encode()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(o.Sourcemap, b, 0o644)
+}
+
+// renderPrompt compiles opts and adds the headers requested in o
+func renderPrompt(opts compile.CompileOptions, o outputOptions) (string, compile.CompileMeta, error) {
 	out, meta, err := compile.Compile(opts)
 	if err != nil {
-		dief("compile error: %v", err)
+		return "", meta, err
+	}
+	for _, u := range meta.UnresolvedVars {
+		fmt.Fprintf(os.Stderr, "warning: unresolved variable: %s\n", u)
 	}
 
-	if *withHash {
+	var prov *format.Provenance
+	if o.Provenance {
+		out = provenanceHeader(meta, o.Profile) + out
+		prov = &format.Provenance{PPC: Version, Profile: o.Profile}
+	}
+
+	if o.Hash {
 		out = fmt.Sprintf("<!-- prompt-id: sha256:%s -->\n\n%s", meta.Hash, out)
 	}
 
-	if *explain {
+	if o.Explain {
 		explainOutput(meta)
 	}
 
-	if *outPath != "" {
-		if err := os.WriteFile(*outPath, []byte(out), 0o644); err != nil {
-			dief("failed to write %s: %v", *outPath, err)
-		}
+	out, err = format.Encode(o.Format, out, meta, prov)
+	return out, meta, err
+}
+
+// printLintTokens lists the token count of each module and the total
+func printLintTokens(result *lint.Result) {
+	ids := make([]string, 0, len(result.Tokens))
+	for id := range result.Tokens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	fmt.Printf("tokens (%s): %d\n", result.Encoding, result.Stats["token_count"])
+	for _, id := range ids {
+		fmt.Printf("  - %s: %d\n", id, result.Tokens[id])
 	}
-	fmt.Print(out)
-	return 0
 }
 
-func printGlobalUsage() {
+func printGlobalUsage(promptsDir string) {
 	fmt.Fprintln(os.Stderr, `usage:
   ppc <subcommand> [flags]
 
+ modes (from `+promptsDir+`/modes):`)
+	var descs map[string]string
+	var names []string
+	if modByID, err := loader.LoadModules(promptsDir); err == nil {
+		descs, names = discoverModes(modByID)
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "  (none found; use --prompts DIR)")
+	}
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, modeDescription(name, descs[name]))
+	}
+	fmt.Fprintln(os.Stderr, `
  subcommands:
-  explore    Generate prompt for exploration mode
-  build      Generate prompt for build mode
-  ship       Generate prompt for shipping mode
+  compile    Compile an explicit selection of modules
+  diff       Compare the prompts compiled from two configurations or git refs
   doctor     Validate module structure and dependencies
   lint       Check prompt policies against lint rules
+  profiles   List profiles or show a resolved profile
+  vendor     Copy pinned module packages into prompts/vendor
 
  global flags:
   --list     List all available modules
@@ -172,11 +537,16 @@ func printGlobalUsage() {
 	ppc explore --conservative --revisions 1 --contract markdown
 	ppc build --conservative --revisions 1 --contract code --explain
 	ppc ship --creative --out AGENTS.md --hash
+	ppc ship --profile ship --out AGENTS.md --provenance
 	ppc explore --guardrails tdd,snake_case
+	ppc explore --trait conservative --trait terse
 	ppc build --guardrails all
 	ppc build --var spec_name=001 --var worktree_path=/tmp/foo --policies spec_context
+	ppc compile --select policies/self_score,guardrails/tdd
 	ppc doctor --strict --json
-  ppc lint --max-words 2000 --require-tags domain:*
+	ppc profiles show ship
+	ppc lint --max-words 2000 --require-tags domain:*
+	ppc lint --fail-on error
 
  run 'ppc <subcommand> --help' for subcommand-specific options`)
 }
@@ -210,17 +580,17 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Default prompts directory
+	promptsDir := "prompts"
+
 	if len(os.Args) < 2 {
-		printGlobalUsage()
+		printGlobalUsage(promptsDir)
 		os.Exit(1)
 	}
 
 	subcommand := os.Args[1]
 	args := os.Args[2:]
 
-	// Default prompts directory
-	promptsDir := "prompts"
-
 	// Handle global meta-flags first
 	if subcommand == "--list" {
 		handleListModules(promptsDir)
@@ -228,22 +598,29 @@ func main() {
 	}
 
 	if subcommand == "--help" || subcommand == "-h" || subcommand == "help" {
-		printGlobalUsage()
+		printGlobalUsage(promptsDir)
 		os.Exit(0)
 	}
 
 	// Dispatch to subcommand
 	switch subcommand {
-	case "explore", "build", "ship":
-		os.Exit(runMode(subcommand, args, promptsDir))
+	case "compile":
+		os.Exit(runCompile(args, promptsDir))
+	case "profiles":
+		os.Exit(runProfiles(args, promptsDir))
+	case "vendor":
+		os.Exit(runVendor(args, promptsDir))
+	case "diff":
+		os.Exit(runDiff(args, promptsDir))
 	case "doctor":
 		fs := flag.NewFlagSet("doctor", flag.ExitOnError)
 		strict := fs.Bool("strict", false, "treat warnings as errors")
 		jsonOut := fs.Bool("json", false, "output machine-readable JSON")
-		withStats := fs.Bool("stats", false, "include module statistics in JSON output")
+		withStats := fs.Bool("stats", false, "include module statistics and token counts in the output")
 		graphOut := fs.Bool("graph", false, "output Graphviz DOT format")
 		outPath := fs.String("out", "", "write output to file")
-		proDir := fs.String("prompts", promptsDir, "prompts directory")
+		proDir := promptsFlag(fs, promptsDir, "prompts directory (repeatable; later roots may override modules)")
+		watchMode := fs.Bool("watch", false, "rerun checks when the prompts directory changes")
 		fs.Usage = func() {
 			fmt.Fprintln(os.Stderr, `usage:
   ppc doctor [flags]
@@ -254,7 +631,13 @@ flags:`)
 			fs.PrintDefaults()
 		}
 		fs.Parse(args)
-		os.Exit(doctor.RunDoctor(*proDir, *strict, *jsonOut, *withStats, *graphOut, *outPath))
+		run := func() int {
+			return doctor.RunDoctor(*proDir, *strict, *jsonOut, *withStats, *graphOut, *outPath)
+		}
+		if *watchMode {
+			os.Exit(watchDoctor(*proDir, run))
+		}
+		os.Exit(run())
 
 	case "lint":
 		fs := flag.NewFlagSet("lint", flag.ExitOnError)
@@ -263,13 +646,16 @@ flags:`)
 		maxModules := fs.Int("max-modules", 0, "maximum number of modules (0=disabled)")
 		maxModuleWords := fs.Int("max-module-words", 0, "maximum words per module (0=disabled)")
 		maxDepth := fs.Int("max-depth", 0, "maximum dependency depth (0=disabled)")
+		maxTokens := fs.Int("max-tokens", 0, "maximum total token count (0=disabled)")
+		showTokens := fs.Bool("tokens", false, "list the token count of each module")
 		requireTags := fs.String("require-tags", "", "comma-separated list of required tags")
 		forbidTags := fs.String("forbid-tags", "", "comma-separated list of forbidden tags")
 		requireFields := fs.String("require-fields", "", "comma-separated list of required frontmatter fields")
 		forbidEmptyBody := fs.Bool("forbid-empty-body", false, "fail if any module has empty body")
 		forbidContent := fs.String("forbid-content", "", "regex pattern forbidden in module bodies")
+		failOn := fs.String("fail-on", "", "lowest severity that fails: error|warn|info|none (default warn)")
 		jsonOut := fs.Bool("json", false, "output machine-readable JSON")
-		proDir := fs.String("prompts", promptsDir, "prompts directory")
+		proDir := promptsFlag(fs, promptsDir, "prompts directory (repeatable; later roots may override modules)")
 		fs.Usage = func() {
 			fmt.Fprintln(os.Stderr, `usage:
   ppc lint [flags]
@@ -292,10 +678,12 @@ flags:`)
 			MaxModules:      *maxModules,
 			MaxModuleWords:  *maxModuleWords,
 			MaxDepth:        *maxDepth,
+			MaxTokens:       *maxTokens,
 			RequireTags:     parseCSV(*requireTags),
 			ForbidTags:      parseCSV(*forbidTags),
 			RequireFields:   parseCSV(*requireFields),
 			ForbidEmptyBody: *forbidEmptyBody,
+			FailOn:          *failOn,
 		}
 
 		if *forbidContent != "" {
@@ -316,6 +704,7 @@ flags:`)
 			MaxModules:     visited["max-modules"],
 			MaxModuleWords: visited["max-module-words"],
 			MaxDepth:       visited["max-depth"],
+			MaxTokens:      visited["max-tokens"],
 		})
 
 		result, err := lint.Run(*proDir, cfg)
@@ -329,30 +718,55 @@ flags:`)
 			if err := enc.Encode(result); err != nil {
 				dief("JSON encode error: %v", err)
 			}
-			if len(result.Violations) > 0 {
+			if result.Failed {
 				os.Exit(2)
 			}
 			os.Exit(0)
 		}
 
+		if *showTokens {
+			printLintTokens(result)
+		}
+
 		if len(result.Violations) == 0 {
 			fmt.Println("lint: OK")
 			os.Exit(0)
 		}
 
-		fmt.Printf("lint: %d issue(s)\n", len(result.Violations))
+		fmt.Printf("lint: %d issue(s) (%d error, %d warn, %d info)\n", len(result.Violations),
+			result.Counts["error"], result.Counts["warn"], result.Counts["info"])
 		for _, v := range result.Violations {
+			scope := ""
+			if v.Scope != "" {
+				scope = " [scope: " + v.Scope + "]"
+			}
 			if v.Module != "" {
-				fmt.Printf("  - [%s] %s: %s (%s)\n", v.Level, v.Rule, v.Message, v.Module)
+				fmt.Printf("  - [%s] %s: %s (%s)%s\n", v.Level, v.Rule, v.Message, v.Module, scope)
 			} else {
-				fmt.Printf("  - [%s] %s: %s\n", v.Level, v.Rule, v.Message)
+				fmt.Printf("  - [%s] %s: %s%s\n", v.Level, v.Rule, v.Message, scope)
 			}
 		}
-		os.Exit(2)
+		if result.Failed {
+			os.Exit(2)
+		}
+		os.Exit(0)
 
 	default:
+		// Any modes/* module in the prompts roots the subcommand would
+		// compile from is a mode subcommand, so a prompts directory that
+		// does not load is reported as such rather than as an unknown mode
+		dir := modePromptsDir(subcommand, args, promptsDir)
+		modByID, err := loadModeModules(dir)
+		if err != nil {
+			dief("%v", err)
+		}
+		descs, names := discoverModes(modByID)
+		if desc, ok := descs[subcommand]; ok {
+			os.Exit(runMode(subcommand, desc, args, promptsDir, dir, modByID))
+		}
 		fmt.Fprintf(os.Stderr, "unknown subcommand: %s\n", subcommand)
-		printGlobalUsage()
+		fmt.Fprintf(os.Stderr, "available modes in %s: %s\n\n", dir, strings.Join(names, ", "))
+		printGlobalUsage(dir)
 		os.Exit(1)
 	}
 }
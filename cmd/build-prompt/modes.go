@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	compilepkg "github.com/bkuri/ppc/internal/compile"
+	"github.com/bkuri/ppc/internal/loader"
+	"github.com/bkuri/ppc/internal/model"
+)
+
+// discoverModes returns the modes defined by modes/* modules in modByID,
+// mapped to each module's desc
+func discoverModes(modByID map[string]*model.Module) (map[string]string, []string) {
+	names := compilepkg.Modes(modByID)
+	descs := make(map[string]string, len(names))
+	for _, name := range names {
+		descs[name] = modByID["modes/"+name].Front.Desc
+	}
+	return descs, names
+}
+
+// modeDescription is the help text for a mode subcommand
+func modeDescription(mode, desc string) string {
+	if desc == "" {
+		return "Generate a prompt for " + mode + " mode."
+	}
+	return desc
+}
+
+// modePromptsDir returns the prompts roots a mode subcommand would compile
+// from: --prompts, else the profile's prompts roots, else def. When args do
+// not parse, only the --prompts values are taken into account.
+func modePromptsDir(mode string, args []string, def string) string {
+	cmd, err := parseModeArgs(mode, "", args, def, "", flag.ContinueOnError)
+	if err != nil {
+		return promptsDirFromArgs(args, def)
+	}
+	dir, err := cmd.promptsDir()
+	if err != nil {
+		return promptsDirFromArgs(args, def)
+	}
+	return dir
+}
+
+// promptsDirFromArgs collects --prompts values in args before flag parsing,
+// so mode subcommands can be discovered from the right directories
+func promptsDirFromArgs(args []string, def string) string {
+	var roots []string
+	for i, a := range args {
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || name != "prompts" {
+			continue
+		}
+		if hasVal {
+			roots = append(roots, val)
+		} else if i+1 < len(args) {
+			roots = append(roots, args[i+1])
+		}
+	}
+	if len(roots) == 0 {
+		return def
+	}
+	return loader.JoinRoots(roots)
+}
+
+// loadModeModules loads the modules mode subcommands are discovered from.
+// Unlike loader.LoadModules, a prompts root that does not exist is an
+// error, since no modes could be found in it.
+func loadModeModules(dir string) (map[string]*model.Module, error) {
+	for _, root := range loader.Roots(dir) {
+		if _, err := os.Stat(root); err != nil {
+			return nil, fmt.Errorf("prompts directory: %w", err)
+		}
+	}
+	return loader.LoadModules(dir)
+}
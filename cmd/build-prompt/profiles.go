@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	profilepkg "github.com/bkuri/ppc/internal/profile"
+	"gopkg.in/yaml.v3"
+)
+
+// runProfiles implements `ppc profiles list|show <name>`
+func runProfiles(args []string, promptsDir string) int {
+	fs := flag.NewFlagSet("profiles", flag.ExitOnError)
+	proDir := promptsFlag(fs, promptsDir, "prompts directory (profiles are searched next to it)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage:
+  ppc profiles list [flags]
+  ppc profiles show <name|path> [flags]
+
+Lists profiles on the search path, or prints a profile's effective
+configuration after extends is resolved.
+
+search path:
+  <prompts>/../profiles, ./profiles, <git root>/profiles,
+  $XDG_CONFIG_HOME/ppc/profiles
+
+flags:`)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 1
+	}
+	action := args[0]
+	// Flags may follow the profile name, so parse again after each
+	// positional argument
+	var names []string
+	rest := args[1:]
+	for {
+		fs.Parse(rest)
+		if fs.NArg() == 0 {
+			break
+		}
+		names = append(names, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+
+	switch action {
+	case "list":
+		entries := profilepkg.List(*proDir)
+		if len(entries) == 0 {
+			fmt.Println("no profiles found")
+			return 0
+		}
+		for _, e := range entries {
+			p, err := profilepkg.LoadProfileFromFile(e.Path)
+			if err != nil {
+				fmt.Printf("%-22s  %-28s  (invalid: %v)\n", e.Name, e.Path, err)
+				continue
+			}
+			fmt.Printf("%-22s  %-28s  %s/%s\n", e.Name, e.Path, p.Mode, p.Contract)
+		}
+		return 0
+
+	case "show":
+		if len(names) != 1 {
+			fs.Usage()
+			return 1
+		}
+		p, path, err := profilepkg.Resolve(names[0], *proDir)
+		if err != nil {
+			dief("profile error: %v", err)
+		}
+		fmt.Printf("# %s\n", path)
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(p); err != nil {
+			dief("yaml encode error: %v", err)
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown profiles action: %s\n", action)
+		fs.Usage()
+		return 1
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bkuri/ppc/internal/compile"
+)
+
+// provenanceHeader renders the compiled-from HTML comment: the PPC version,
+// profile, rules.yml hash and every module in meta.Order with its source
+// path and content hash. The block is valid YAML between the comment markers.
+func provenanceHeader(meta compile.CompileMeta, profile string) string {
+	var b strings.Builder
+	b.WriteString("<!--\ncompiled-from:\n")
+	fmt.Fprintf(&b, "  ppc: %s\n", yamlString(Version))
+	if profile != "" {
+		fmt.Fprintf(&b, "  profile: %s\n", yamlString(profile))
+	}
+	fmt.Fprintf(&b, "  rules: sha256:%s\n", meta.RulesHash)
+	b.WriteString("  modules:\n")
+	for _, s := range meta.Sources {
+		fmt.Fprintf(&b, "    - id: %s\n", yamlString(s.ID))
+		fmt.Fprintf(&b, "      path: %s\n", yamlString(s.Path))
+		fmt.Fprintf(&b, "      sha256: %s\n", s.Hash)
+	}
+	b.WriteString("-->\n\n")
+	return b.String()
+}
+
+// yamlString quotes s as a YAML double-quoted scalar that is also safe
+// inside an HTML comment: "--" is written as "-\x2d", so a value can never
+// close the comment early
+func yamlString(s string) string {
+	return strings.ReplaceAll(strconv.Quote(s), "--", `-\x2d`)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bkuri/ppc/internal/vendoring"
+)
+
+// runVendor implements `ppc vendor`
+func runVendor(args []string, promptsDir string) int {
+	fs := flag.NewFlagSet("vendor", flag.ExitOnError)
+	proDir := fs.String("prompts", promptsDir, "prompts directory holding vendor.yml")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage:
+  ppc vendor [flags]
+
+Copies the packages listed in <prompts>/vendor.yml into <prompts>/vendor/
+and pins them in <prompts>/ppc.lock. Sources are local git repositories
+(with a ref) or tarballs; nothing is fetched over the network.
+
+flags:`)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	lock, err := vendoring.Vendor(*proDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vendor: %v\n", err)
+		return 2
+	}
+	for _, p := range lock.Packages {
+		commit := strings.TrimPrefix(p.Commit, "sha256:")
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		fmt.Printf("vendored %s @ %s (%d files)\n", p.Name, commit, len(p.Files))
+	}
+	fmt.Printf("wrote %s\n", filepath.Join(*proDir, vendoring.LockFile))
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bkuri/ppc/internal/compile"
+	"github.com/bkuri/ppc/internal/doctor"
+	"github.com/bkuri/ppc/internal/loader"
+	"github.com/bkuri/ppc/internal/watch"
+)
+
+// watchPrompt compiles the prompt, then recompiles whenever a file it
+// depends on changes. Errors are reported and watching continues.
+func watchPrompt(resolve func() (compile.CompileOptions, outputOptions, []string, error)) int {
+	last := ""
+	for {
+		opts, o, watched, err := resolve()
+		snap := watch.Take(watched)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+		} else {
+			last = recompile(opts, o, last)
+		}
+		fmt.Fprintln(os.Stderr, "watch: waiting for changes (Ctrl-C to stop)")
+		watch.Wait(watched, snap, watch.Interval)
+	}
+}
+
+// recompile compiles once for watchPrompt. Out is only rewritten when its
+// content changes; without Out, the prompt is printed when it differs from
+// last. It returns the latest prompt, or last when compilation failed.
+func recompile(opts compile.CompileOptions, o outputOptions, last string) string {
+	if f, err := doctor.Diagnose(opts.PromptsDir); err == nil {
+		for _, e := range f.Errors {
+			fmt.Fprintf(os.Stderr, "watch: doctor: %s\n", e)
+		}
+	}
+
+	out, meta, err := renderPrompt(opts, o)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "watch: compile error: %v\n", err)
+		return last
+	}
+
+	if o.Out != "" {
+		last = ""
+		if b, err := os.ReadFile(o.Out); err == nil {
+			last = string(b)
+		}
+	}
+	if out == last {
+		fmt.Fprintf(os.Stderr, "watch: unchanged sha256:%s\n", meta.Hash)
+		return out
+	}
+	if err := writeSourcemap(o, out, meta); err != nil {
+		fmt.Fprintf(os.Stderr, "watch: sourcemap: %v\n", err)
+	}
+
+	if o.Out == "" {
+		fmt.Print(out)
+	} else if err := os.WriteFile(o.Out, []byte(out), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "watch: failed to write %s: %v\n", o.Out, err)
+		return last
+	}
+	fmt.Fprintf(os.Stderr, "watch: compiled sha256:%s\n", meta.Hash)
+	return out
+}
+
+// watchDoctor calls run, then calls it again whenever a file in the
+// prompts roots changes
+func watchDoctor(promptsDir string, run func() int) int {
+	roots := loader.Roots(promptsDir)
+	for {
+		snap := watch.Take(roots)
+		run()
+		fmt.Fprintln(os.Stderr, "watch: waiting for changes (Ctrl-C to stop)")
+		watch.Wait(roots, snap, watch.Interval)
+	}
+}
@@ -4,57 +4,98 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	errtypes "github.com/bkuri/ppc/internal/error"
 	"github.com/bkuri/ppc/internal/loader"
 	"github.com/bkuri/ppc/internal/model"
 	"github.com/bkuri/ppc/internal/render"
 	"github.com/bkuri/ppc/internal/resolver"
-	"github.com/bkuri/ppc/internal/substitute"
-	"gopkg.in/yaml.v3"
+	"github.com/bkuri/ppc/internal/tokens"
 )
 
 func Compile(opts CompileOptions) (string, CompileMeta, error) {
-	modByID, err := loader.LoadModules(opts.PromptsDir)
+	modByID := opts.Modules
+	if modByID == nil {
+		var err error
+		modByID, err = loader.LoadModules(opts.PromptsDir)
+		if err != nil {
+			return "", CompileMeta{}, err
+		}
+	}
+
+	rules, err := loader.LoadRules(opts.PromptsDir)
 	if err != nil {
 		return "", CompileMeta{}, err
 	}
 
-	rules, err := loader.LoadRules(opts.PromptsDir)
+	vars, origins, err := loadVars(opts)
 	if err != nil {
 		return "", CompileMeta{}, err
 	}
 
-	vars := substitute.Vars{}
-	if opts.VarsFile != "" {
-		vars, err = loadVarsFile(opts.VarsFile)
-		if err != nil {
+	if len(opts.Select) == 0 || opts.Mode != "" {
+		if err := checkMode(opts.Mode, modByID); err != nil {
 			return "", CompileMeta{}, err
 		}
 	}
-	for k, v := range opts.Vars {
-		vars[k] = v
+	for _, id := range opts.Select {
+		if _, ok := modByID[id]; !ok {
+			return "", CompileMeta{}, errtypes.New("", id, fmt.Sprintf("selected module not found: %s", id))
+		}
 	}
 
 	selectedIDs := buildSelectedIDs(opts)
 
-	closureIDs, fromReq, err := resolver.ExpandRequires(selectedIDs, modByID)
+	closureIDs, fromReq, err := resolver.ExpandRequires(selectedIDs, opts.Profiles, modByID)
 	if err != nil {
 		return "", CompileMeta{}, err
 	}
 
-	mods, order := buildModuleList(closureIDs, fromReq, selectedIDs, modByID)
+	if err := resolver.ValidateConflicts(selectedIDs, closureIDs, modByID); err != nil {
+		return "", CompileMeta{}, err
+	}
+
+	scope := resolver.ClosureScope(closureIDs, opts.Profiles)
+	if err := resolver.ValidateAppliesTo(selectedIDs, closureIDs, scope, modByID); err != nil {
+		return "", CompileMeta{}, err
+	}
+
+	mods := buildModuleList(closureIDs, fromReq, selectedIDs, modByID)
 
 	if err := resolver.ValidateExclusiveGroups(rules, mods); err != nil {
 		return "", CompileMeta{}, err
 	}
 
 	sortedMods := resolver.SortModules(mods)
+	order := make([]string, 0, len(sortedMods))
+	for _, m := range sortedMods {
+		order = append(order, m.Front.ID)
+	}
 
-	out, unresolved := render.Render(sortedMods, vars)
+	vars, err = applyVarSchema(sortedMods, vars, origins)
+	if err != nil {
+		return "", CompileMeta{}, err
+	}
 
-	for _, u := range unresolved {
-		fmt.Fprintf(os.Stderr, "warning: unresolved variable: %s\n", u)
+	segs, unres := render.Segments(sortedMods, vars, render.Options{SkipCodeBlocks: rules.Substitution.SkipCodeBlocks})
+	out := render.Join(segs)
+
+	unresolved := buildUnresolved(unres)
+	if opts.StrictVars && len(unresolved) > 0 {
+		return "", CompileMeta{}, strictVarsError(unresolved)
+	}
+
+	enc := tokens.Default()
+	total := enc.Count(out)
+	maxTokens := opts.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = rules.Lint.MaxTokens
+	}
+	if maxTokens > 0 && total > maxTokens {
+		return "", CompileMeta{}, fmt.Errorf("prompt has %d tokens (%s), over the max_tokens budget of %d", total, enc.Name(), maxTokens)
 	}
 
 	h := sha256.Sum256([]byte(out))
@@ -66,21 +107,39 @@ func Compile(opts CompileOptions) (string, CompileMeta, error) {
 		Order:          order,
 		Hash:           hash,
 		UnresolvedVars: unresolved,
+		Sources:        buildSources(order, modByID),
+		Segments:       buildSegments(segs, rules.LayerOrder(), enc),
+		RulesHash:      rules.Hash,
+		Tokens:         total,
+		Encoding:       enc.Name(),
+		Optional:       buildOptional(closureIDs, modByID),
+		VarSources:     origins.list(),
 	}
 
 	return out, meta, nil
 }
 
-func loadVarsFile(path string) (substitute.Vars, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
+// Modes returns the names of all modes/* modules, sorted
+func Modes(modByID map[string]*model.Module) []string {
+	var modes []string
+	for id := range modByID {
+		if name, ok := strings.CutPrefix(id, "modes/"); ok {
+			modes = append(modes, name)
+		}
 	}
-	var vars substitute.Vars
-	if err := yaml.Unmarshal(data, &vars); err != nil {
-		return nil, err
+	sort.Strings(modes)
+	return modes
+}
+
+func checkMode(mode string, modByID map[string]*model.Module) error {
+	if _, ok := modByID["modes/"+mode]; ok {
+		return nil
 	}
-	return vars, nil
+	available := Modes(modByID)
+	if len(available) == 0 {
+		return fmt.Errorf("unknown mode %q (no modes/* modules found)", mode)
+	}
+	return fmt.Errorf("unknown mode %q (available: %s)", mode, strings.Join(available, ", "))
 }
 
 func buildSelectedIDs(opts CompileOptions) []string {
@@ -89,6 +148,23 @@ func buildSelectedIDs(opts CompileOptions) []string {
 		"modes/" + opts.Mode,
 		"contracts/" + opts.Contract,
 	}
+	if len(opts.Select) > 0 {
+		selectedIDs = nil
+		if opts.IncludeBase {
+			selectedIDs = append(selectedIDs, "base")
+		}
+		if opts.Mode != "" {
+			selectedIDs = append(selectedIDs, "modes/"+opts.Mode)
+		}
+		if opts.Contract != "" {
+			selectedIDs = append(selectedIDs, "contracts/"+opts.Contract)
+		}
+		for _, id := range opts.Select {
+			if !resolver.Contains(selectedIDs, id) {
+				selectedIDs = append(selectedIDs, id)
+			}
+		}
+	}
 	selectedIDs = append(selectedIDs, opts.Traits...)
 	for _, p := range opts.Policies {
 		selectedIDs = append(selectedIDs, "policies/"+p)
@@ -99,22 +175,87 @@ func buildSelectedIDs(opts CompileOptions) []string {
 	return selectedIDs
 }
 
+func buildUnresolved(unres []render.Unresolved) []UnresolvedVar {
+	out := make([]UnresolvedVar, 0, len(unres))
+	for _, u := range unres {
+		out = append(out, UnresolvedVar{
+			Name:   u.Name,
+			Module: u.Module.Front.ID,
+			Path:   filepath.ToSlash(u.Module.Path),
+			Line:   u.Module.Line + u.BodyLine - 1,
+		})
+	}
+	return out
+}
+
+// strictVarsError lists every unresolved occurrence, pointing at the first
+func strictVarsError(unresolved []UnresolvedVar) error {
+	locs := make([]string, 0, len(unresolved))
+	for _, u := range unresolved {
+		locs = append(locs, u.String())
+	}
+	first := unresolved[0]
+	return errtypes.NewAtLine(first.Path, first.Module,
+		fmt.Sprintf("unresolved variables (--strict-vars): %s", strings.Join(locs, ", ")), first.Line)
+}
+
+func buildOptional(closureIDs []string, modByID map[string]*model.Module) []OptionalDep {
+	var deps []OptionalDep
+	for _, d := range resolver.OptionalDeps(closureIDs, modByID) {
+		deps = append(deps, OptionalDep(d))
+	}
+	return deps
+}
+
+func buildSources(order []string, modByID map[string]*model.Module) []ModuleSource {
+	sources := make([]ModuleSource, 0, len(order))
+	for _, id := range order {
+		m := modByID[id]
+		sources = append(sources, ModuleSource{
+			ID:      id,
+			Path:    filepath.ToSlash(m.Path),
+			Root:    filepath.ToSlash(m.Root),
+			Hash:    m.Hash,
+			Version: m.Front.Version,
+		})
+	}
+	return sources
+}
+
+func buildSegments(segs []render.Segment, layers []string, enc tokens.Encoding) []Segment {
+	out := make([]Segment, 0, len(segs))
+	for _, s := range segs {
+		lines := make([]Line, 0, len(s.Lines))
+		for _, l := range s.Lines {
+			lines = append(lines, Line{BodyLine: l.BodyLine, Substituted: l.Substituted})
+		}
+		out = append(out, Segment{
+			ID:     s.Module.Front.ID,
+			Layer:  model.LayerNameIn(layers, s.Module.Layer),
+			Path:   filepath.ToSlash(s.Module.Path),
+			Body:   s.Body,
+			Tokens: enc.Count(s.Body),
+			Line:   s.Module.Line,
+			Lines:  lines,
+		})
+	}
+	return out
+}
+
 func buildModuleList(
 	closureIDs []string,
 	fromReq map[string]bool,
 	selectedIDs []string,
 	modByID map[string]*model.Module,
-) ([]*model.Module, []string) {
+) []*model.Module {
 	var mods []*model.Module
-	var order []string
 
 	for _, id := range closureIDs {
 		m := modByID[id]
 		m.FromReq = fromReq[id]
 		m.Selected = resolver.Contains(selectedIDs, id)
 		mods = append(mods, m)
-		order = append(order, id)
 	}
 
-	return mods, order
+	return mods
 }
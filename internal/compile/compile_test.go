@@ -1,6 +1,10 @@
 package compile
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -42,6 +46,21 @@ func TestCompile(t *testing.T) {
 			t.Errorf("Order length %d != ClosureIDs length %d",
 				len(meta.Order), len(meta.ClosureIDs))
 		}
+
+	})
+
+	t.Run("order follows rendering", func(t *testing.T) {
+		_, meta, err := Compile(CompileOptions{
+			PromptsDir: "testdata",
+			Select:     []string{"contracts/simple", "modes/explore"},
+		})
+		if err != nil {
+			t.Fatalf("Compile failed: %s", err)
+		}
+		want := []string{"base", "modes/explore", "contracts/simple"}
+		if strings.Join(meta.Order, ",") != strings.Join(want, ",") {
+			t.Errorf("Order = %v, want %v", meta.Order, want)
+		}
 	})
 
 	t.Run("nonexistent prompts directory", func(t *testing.T) {
@@ -68,6 +87,9 @@ func TestCompile(t *testing.T) {
 		if err == nil {
 			t.Fatal("expected error for missing mode module")
 		}
+		if !strings.Contains(err.Error(), "available: explore") {
+			t.Errorf("error should list available modes, got: %v", err)
+		}
 	})
 
 	t.Run("missing contract module", func(t *testing.T) {
@@ -122,6 +144,275 @@ func TestCompile(t *testing.T) {
 		}
 	})
 
+	t.Run("sources follow order", func(t *testing.T) {
+		opts := CompileOptions{
+			Mode:       "explore",
+			Contract:   "simple",
+			PromptsDir: "testdata",
+		}
+
+		_, meta, err := Compile(opts)
+		if err != nil {
+			t.Fatalf("Compile failed: %s", err)
+		}
+
+		if len(meta.Sources) != len(meta.Order) {
+			t.Fatalf("Sources length %d != Order length %d", len(meta.Sources), len(meta.Order))
+		}
+		for i, src := range meta.Sources {
+			if src.ID != meta.Order[i] {
+				t.Errorf("Sources[%d].ID = %q, want %q", i, src.ID, meta.Order[i])
+			}
+			if len(src.Hash) != 64 {
+				t.Errorf("Sources[%d].Hash length = %d, want 64", i, len(src.Hash))
+			}
+			if !strings.HasPrefix(src.Path, "testdata/") {
+				t.Errorf("Sources[%d].Path = %q, want testdata/ prefix", i, src.Path)
+			}
+		}
+		if len(meta.RulesHash) != 64 {
+			t.Errorf("RulesHash length = %d, want 64", len(meta.RulesHash))
+		}
+	})
+
+	t.Run("explicit selection", func(t *testing.T) {
+		opts := CompileOptions{
+			Select:     []string{"policies/review"},
+			PromptsDir: "testdata",
+		}
+
+		out, meta, err := Compile(opts)
+		if err != nil {
+			t.Fatalf("Compile failed: %s", err)
+		}
+		if out != "Review every change before approving.\n" {
+			t.Errorf("out = %q, want only the selected module", out)
+		}
+		if len(meta.Order) != 1 || meta.Order[0] != "policies/review" {
+			t.Errorf("Order = %v, want [policies/review]", meta.Order)
+		}
+	})
+
+	t.Run("explicit selection expands requires", func(t *testing.T) {
+		opts := CompileOptions{
+			Select:     []string{"policies/review", "contracts/simple"},
+			PromptsDir: "testdata",
+		}
+
+		_, meta, err := Compile(opts)
+		if err != nil {
+			t.Fatalf("Compile failed: %s", err)
+		}
+		if !contains(meta.ClosureIDs, "base") {
+			t.Errorf("ClosureIDs = %v, want base pulled in by contracts/simple", meta.ClosureIDs)
+		}
+		if contains(meta.ClosureIDs, "modes/explore") {
+			t.Errorf("ClosureIDs = %v, mode should not be injected", meta.ClosureIDs)
+		}
+	})
+
+	t.Run("explicit selection with injection", func(t *testing.T) {
+		opts := CompileOptions{
+			Select:      []string{"policies/review"},
+			IncludeBase: true,
+			Mode:        "explore",
+			PromptsDir:  "testdata",
+		}
+
+		_, meta, err := Compile(opts)
+		if err != nil {
+			t.Fatalf("Compile failed: %s", err)
+		}
+		for _, id := range []string{"base", "modes/explore", "policies/review"} {
+			if !contains(meta.SelectedIDs, id) {
+				t.Errorf("SelectedIDs = %v, missing %s", meta.SelectedIDs, id)
+			}
+		}
+	})
+
+	t.Run("explicit selection unknown module", func(t *testing.T) {
+		opts := CompileOptions{
+			Select:     []string{"policies/missing"},
+			PromptsDir: "testdata",
+		}
+
+		_, _, err := Compile(opts)
+		if err == nil || !strings.Contains(err.Error(), "selected module not found") {
+			t.Fatalf("expected selected module error, got %v", err)
+		}
+	})
+
+	t.Run("conflicting modules", func(t *testing.T) {
+		_, _, err := Compile(CompileOptions{
+			PromptsDir: "testdata",
+			Select:     []string{"policies/review", "policies/rush"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "conflicting modules: policies/review (selected) conflicts with policies/rush (selected)") {
+			t.Fatalf("expected conflict error, got %v", err)
+		}
+	})
+
+	t.Run("applies_to", func(t *testing.T) {
+		opts := CompileOptions{
+			Mode:       "explore",
+			Contract:   "simple",
+			Policies:   []string{"explore_only"},
+			PromptsDir: "testdata",
+		}
+		_, _, err := Compile(opts)
+		if err == nil || !strings.Contains(err.Error(), "applies_to profiles: research; compiling profiles: none") {
+			t.Fatalf("expected applies_to error, got %v", err)
+		}
+		opts.Profiles = []string{"research"}
+		if _, _, err := Compile(opts); err != nil {
+			t.Errorf("Compile in scope failed: %v", err)
+		}
+	})
+
+	t.Run("declared vars", func(t *testing.T) {
+		compileBudget := func(vars map[string]any) (string, error) {
+			out, _, err := Compile(CompileOptions{Select: []string{"policies/budget"}, PromptsDir: "testdata", Vars: vars})
+			return out, err
+		}
+		if _, err := compileBudget(nil); err == nil || !strings.Contains(err.Error(), "missing required variable: owner") {
+			t.Errorf("expected missing variable error, got %v", err)
+		}
+		out, err := compileBudget(map[string]any{"owner": "ops"})
+		if err != nil || out != "ops approves up to 3 changes.\n" {
+			t.Errorf("default not applied: %q, %v", out, err)
+		}
+		out, err = compileBudget(map[string]any{"owner": "ops", "budget": "5"})
+		if err != nil || out != "ops approves up to 5 changes.\n" {
+			t.Errorf("string value not converted: %q, %v", out, err)
+		}
+		if _, err := compileBudget(map[string]any{"owner": "ops", "budget": "lots"}); err == nil ||
+			!strings.Contains(err.Error(), `variable budget: expected int, got string "lots"`) {
+			t.Errorf("expected type error, got %v", err)
+		}
+	})
+
+	t.Run("vars sources", func(t *testing.T) {
+		dir := t.TempDir()
+		write := func(name, content string) string {
+			p := filepath.Join(dir, name)
+			if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}
+		base := write("base.yml", "owner: dev\nteam:\n  name: core\n  size: 4\n")
+		local := write("local.env", "# overrides\nTEAM__SIZE=6\n")
+		t.Setenv("PPCTEST_OWNER", "ops")
+
+		_, meta, err := Compile(CompileOptions{
+			Select:      []string{"policies/budget"},
+			PromptsDir:  "testdata",
+			VarsFile:    base,
+			VarsFiles:   []string{local},
+			ProfileVars: map[string]any{"team": map[string]any{"lead": "ana"}},
+			VarsEnv:     "PPCTEST_",
+		})
+		if err != nil {
+			t.Fatalf("Compile failed: %v", err)
+		}
+		want := []VarSource{
+			{Name: "budget", Source: "default:policies/budget"},
+			{Name: "owner", Source: "env:PPCTEST_OWNER"},
+			{Name: "team.lead", Source: "profile"},
+			{Name: "team.name", Source: "file:" + base},
+			{Name: "team.size", Source: "file:" + local},
+		}
+		if !reflect.DeepEqual(meta.VarSources, want) {
+			t.Errorf("VarSources = %+v, want %+v", meta.VarSources, want)
+		}
+
+		out, meta, err := Compile(CompileOptions{
+			Select:     []string{"policies/budget"},
+			PromptsDir: "testdata",
+			VarsFiles:  []string{base},
+			VarsEnv:    "PPCTEST_",
+			Vars:       map[string]any{"owner": "cli", "team": "flat"},
+		})
+		if err != nil || out != "cli approves up to 3 changes.\n" {
+			t.Fatalf("Vars did not take precedence: %q, %v", out, err)
+		}
+		if got := meta.VarSources[2]; got != (VarSource{Name: "team", Source: "var"}) || len(meta.VarSources) != 3 {
+			t.Errorf("replaced map kept nested sources: %+v", meta.VarSources)
+		}
+
+		bad := write(".env", "OWNER\n")
+		if _, _, err := Compile(CompileOptions{Select: []string{"policies/budget"}, PromptsDir: "testdata", VarsFile: bad}); err == nil ||
+			!strings.Contains(err.Error(), ".env: line 1: expected KEY=VALUE") {
+			t.Errorf("expected dotenv error, got %v", err)
+		}
+	})
+
+	t.Run("unresolved vars in meta", func(t *testing.T) {
+		opts := CompileOptions{Select: []string{"policies/handoff"}, PromptsDir: "testdata", Vars: map[string]any{"change": "PR 7"}}
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr := os.Stderr
+		os.Stderr = w
+		_, meta, err := Compile(opts)
+		os.Stderr = stderr
+		w.Close()
+		printed, _ := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Compile failed: %v", err)
+		}
+		if len(printed) > 0 {
+			t.Errorf("Compile wrote to stderr: %q", printed)
+		}
+		want := []UnresolvedVar{{Name: "reviewer", Module: "policies/handoff", Path: "testdata/policies/handoff.md", Line: 6}}
+		if !reflect.DeepEqual(meta.UnresolvedVars, want) {
+			t.Errorf("UnresolvedVars = %+v, want %+v", meta.UnresolvedVars, want)
+		}
+
+		opts.StrictVars = true
+		_, _, err = Compile(opts)
+		if err == nil || !strings.Contains(err.Error(), "unresolved variables (--strict-vars): reviewer (policies/handoff, testdata/policies/handoff.md:6)") {
+			t.Errorf("expected strict vars error, got %v", err)
+		}
+		opts.Vars["reviewer"] = "ops"
+		if _, _, err := Compile(opts); err != nil {
+			t.Errorf("strict compile with all vars failed: %v", err)
+		}
+	})
+
+	t.Run("token counts", func(t *testing.T) {
+		_, meta, err := Compile(CompileOptions{Mode: "explore", Contract: "simple", PromptsDir: "testdata"})
+		if err != nil {
+			t.Fatalf("Compile failed: %s", err)
+		}
+		if meta.Tokens == 0 || meta.Encoding == "" {
+			t.Errorf("Tokens = %d, Encoding = %q; want a count", meta.Tokens, meta.Encoding)
+		}
+		sum := 0
+		for _, s := range meta.Segments {
+			if s.Tokens == 0 {
+				t.Errorf("segment %s has no tokens", s.ID)
+			}
+			sum += s.Tokens
+		}
+		if sum > meta.Tokens {
+			t.Errorf("segment tokens %d exceed prompt tokens %d", sum, meta.Tokens)
+		}
+	})
+
+	t.Run("max tokens", func(t *testing.T) {
+		opts := CompileOptions{Mode: "explore", Contract: "simple", PromptsDir: "testdata", MaxTokens: 1}
+		_, _, err := Compile(opts)
+		if err == nil || !strings.Contains(err.Error(), "max_tokens budget of 1") {
+			t.Fatalf("expected token budget error, got %v", err)
+		}
+		opts.MaxTokens = 100000
+		if _, _, err := Compile(opts); err != nil {
+			t.Errorf("Compile within budget failed: %v", err)
+		}
+	})
+
 	t.Run("buildSelectedIDs", func(t *testing.T) {
 		opts := CompileOptions{
 			Mode:     "explore",
@@ -141,3 +432,12 @@ func TestCompile(t *testing.T) {
 		}
 	})
 }
+
+func contains(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
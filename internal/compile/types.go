@@ -1,22 +1,132 @@
 // Package compile provides the core compilation API
 package compile
 
+import (
+	"fmt"
+
+	"github.com/bkuri/ppc/internal/model"
+)
+
 type CompileOptions struct {
 	Mode       string
 	Contract   string
 	Traits     []string
 	Guardrails []string
 	Policies   []string
+	// PromptsDir is a prompts directory, or several prompts roots in
+	// precedence order separated by os.PathListSeparator
 	PromptsDir string
-	VarsFile   string
-	Vars       map[string]any
+	// VarsFile and then each of VarsFiles are deep-merged in order. A
+	// file named .env, *.env or .env.* is read as a dotenv file, any other
+	// as YAML.
+	VarsFile  string
+	VarsFiles []string
+	// ProfileVars are the profile's vars, merged over the files
+	ProfileVars map[string]any
+	// VarsEnv, when set, merges environment variables starting with this
+	// prefix over the profile's vars (PPC_PROJECT__NAME -> project.name)
+	VarsEnv string
+	// Vars take precedence over every other source
+	Vars map[string]any
+	// Select lists module IDs to compile explicitly. When set, base, Mode
+	// and Contract are only injected if IncludeBase, Mode or Contract ask
+	// for them; otherwise all three are always selected.
+	Select      []string
+	IncludeBase bool
+	// MaxTokens fails the compile when the prompt has more tokens. Zero
+	// uses lint.max_tokens from rules.yml; a negative value disables it.
+	MaxTokens int
+	// Profiles names the profile in use and the profiles it extends,
+	// for applies_to
+	Profiles []string
+	// StrictVars fails the compile when any placeholder is unresolved
+	StrictVars bool
+	// Modules, when set, holds the modules already loaded from PromptsDir
+	// and is used instead of loading them again
+	Modules map[string]*model.Module
 }
 
 // CompileMeta provides metadata about the compilation
 type CompileMeta struct {
-	SelectedIDs    []string
-	ClosureIDs     []string
-	Order          []string
-	Hash           string
-	UnresolvedVars []string
+	SelectedIDs []string
+	ClosureIDs  []string
+	// Order lists module IDs in the order they are rendered
+	Order []string
+	Hash  string
+	// UnresolvedVars lists every placeholder left unsubstituted, in
+	// output order
+	UnresolvedVars []UnresolvedVar
+	Sources        []ModuleSource
+	RulesHash      string
+	// Segments holds each module's rendered body, in Order
+	Segments []Segment
+	// Tokens is the token count of the prompt in Encoding
+	Tokens   int
+	Encoding string
+	// Optional lists the suggests and requires_any edges of the closure
+	Optional []OptionalDep
+	// VarSources lists every variable value used for substitution and
+	// where it came from, sorted by Name
+	VarSources []VarSource
+}
+
+// VarSource records which source supplied the final value of a variable
+// path: "file:PATH", "profile", "env:NAME", "var" or "default:MODULE"
+type VarSource struct {
+	Name   string
+	Source string
+}
+
+// OptionalDep records how a suggests or requires_any edge was resolved.
+// Kind is "suggests" or "requires_any"; Match is the target that was
+// compiled, or empty. Missing marks a suggested module that does not exist.
+type OptionalDep struct {
+	From    string
+	Kind    string
+	Targets []string
+	Match   string
+	Missing bool
+}
+
+// UnresolvedVar is an occurrence of a variable no value was supplied for.
+// Line is the line of Path holding the placeholder.
+type UnresolvedVar struct {
+	Name   string
+	Module string
+	Path   string
+	Line   int
+}
+
+func (u UnresolvedVar) String() string {
+	return fmt.Sprintf("%s (%s, %s:%d)", u.Name, u.Module, u.Path, u.Line)
+}
+
+// Segment is the rendered body of one module
+type Segment struct {
+	ID     string
+	Layer  string
+	Path   string
+	Body   string
+	Tokens int
+	// Line is the line of Path on which the module body starts; Lines
+	// maps each line of Body to the body line it was rendered from
+	Line  int
+	Lines []Line
+}
+
+// Line is the origin of a rendered line: a 1-based line of the module
+// body, and whether a variable was substituted into it
+type Line struct {
+	BodyLine    int
+	Substituted bool
+}
+
+// ModuleSource records the file a compiled module was read from
+type ModuleSource struct {
+	ID   string
+	Path string
+	Root string
+	Hash string
+	// Version is the module's declared version, if any
+	Version string
 }
@@ -0,0 +1,177 @@
+package compile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	errtypes "github.com/bkuri/ppc/internal/error"
+	"github.com/bkuri/ppc/internal/model"
+	"github.com/bkuri/ppc/internal/substitute"
+	"gopkg.in/yaml.v3"
+)
+
+// varDecl is a variable declared by one or more compiled modules
+type varDecl struct {
+	spec model.VarSpec
+	// by is the first module declaring the variable
+	by *model.Module
+}
+
+// declaredVars merges the vars declarations of mods. A variable declared
+// by several modules must have the same type in each; it is required if
+// any of them requires it, and takes the first default given.
+func declaredVars(mods []*model.Module) (map[string]*varDecl, error) {
+	decls := map[string]*varDecl{}
+	for _, m := range mods {
+		names := make([]string, 0, len(m.Front.Vars))
+		for name := range m.Front.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			spec := m.Front.Vars[name]
+			d, ok := decls[name]
+			if !ok {
+				decls[name] = &varDecl{spec: spec, by: m}
+				continue
+			}
+			if typeOf(d.spec) != typeOf(spec) {
+				return nil, errtypes.New(m.Path, m.Front.ID, fmt.Sprintf("variable %s declared as %s by %s and as %s by %s",
+					name, typeOf(d.spec), d.by.Front.ID, typeOf(spec), m.Front.ID))
+			}
+			d.spec.Required = d.spec.Required || spec.Required
+			if d.spec.Default == nil {
+				d.spec.Default = spec.Default
+			}
+		}
+	}
+	return decls, nil
+}
+
+func typeOf(spec model.VarSpec) string {
+	if spec.Type == "" {
+		return substitute.TypeString
+	}
+	return spec.Type
+}
+
+// applyVarSchema validates vars against the declarations of mods and fills
+// in defaults, recording them in origins. Supplied values are converted to
+// the declared type.
+func applyVarSchema(mods []*model.Module, vars substitute.Vars, origins varOrigins) (substitute.Vars, error) {
+	decls, err := declaredVars(mods)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(decls))
+	for name := range decls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d := decls[name]
+		val, ok := substitute.ResolvePath(vars, name)
+		switch {
+		case ok:
+			v, err := substitute.Coerce(d.spec.Type, val)
+			if err != nil {
+				return nil, errtypes.New(d.by.Path, d.by.Front.ID, fmt.Sprintf("variable %s: %v", name, err))
+			}
+			vars = substitute.SetPath(vars, name, v)
+		case d.spec.Default != nil:
+			v, _ := substitute.Coerce(d.spec.Type, d.spec.Default)
+			vars = substitute.SetPath(vars, name, v)
+			origins.set(name, "default:"+d.by.Front.ID)
+		case d.spec.Required:
+			return nil, errtypes.New(d.by.Path, d.by.Front.ID, fmt.Sprintf("missing required variable: %s (declared by %s)", name, d.by.Front.ID))
+		}
+	}
+	return vars, nil
+}
+
+// varOrigins maps each leaf path of the merged vars to the source that
+// supplied it
+type varOrigins map[string]string
+
+// set records source for path, dropping the origins of the values it
+// replaced: its parents and anything nested under it
+func (o varOrigins) set(path, source string) {
+	for p := range o {
+		if strings.HasPrefix(p, path+".") || strings.HasPrefix(path, p+".") {
+			delete(o, p)
+		}
+	}
+	o[path] = source
+}
+
+func (o varOrigins) list() []VarSource {
+	out := make([]VarSource, 0, len(o))
+	for name, source := range o {
+		out = append(out, VarSource{Name: name, Source: source})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// loadVars deep-merges the variable sources of opts, from lowest to highest
+// precedence: vars files in order, profile vars, the environment and Vars
+func loadVars(opts CompileOptions) (substitute.Vars, varOrigins, error) {
+	vars, origins := substitute.Vars{}, varOrigins{}
+	merge := func(v substitute.Vars, source func(path string) string) {
+		vars = substitute.Merge(vars, v)
+		for _, p := range substitute.Leaves(v) {
+			origins.set(p, source(p))
+		}
+	}
+	label := func(s string) func(string) string {
+		return func(string) string { return s }
+	}
+
+	files := opts.VarsFiles
+	if opts.VarsFile != "" {
+		files = append([]string{opts.VarsFile}, files...)
+	}
+	for _, f := range files {
+		v, err := loadVarsFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		merge(v, label("file:"+f))
+	}
+	merge(opts.ProfileVars, label("profile"))
+	if opts.VarsEnv != "" {
+		env, names := substitute.FromEnv(os.Environ(), opts.VarsEnv)
+		merge(env, func(p string) string { return "env:" + names[p] })
+	}
+	merge(opts.Vars, label("var"))
+	return vars, origins, nil
+}
+
+func loadVarsFile(path string) (substitute.Vars, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isDotenv(path) {
+		vars, err := substitute.ParseDotenv(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return vars, nil
+	}
+	var vars substitute.Vars
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// isDotenv reports whether path names a dotenv file: .env, .env.* or *.env
+func isDotenv(path string) bool {
+	name := filepath.Base(path)
+	return name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env")
+}
@@ -0,0 +1,259 @@
+// Package diff compares compiled prompts line by line and by module order.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change
+const Context = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	a, b int // line indexes in a and b
+}
+
+// Unified returns a unified diff from a to b, or "" when they are equal.
+// aName and bName label the two sides in the --- and +++ header lines.
+func Unified(aName, bName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	al, bl := splitLines(a), splitLines(b)
+	ops := lineOps(al, bl)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for _, h := range hunks(ops) {
+		writeHunk(&sb, ops[h[0]:h[1]], al, bl)
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps computes an edit script from a to b with Myers' algorithm.
+// Before each round d, the trace keeps the diagonals round d can read,
+// k = -d-1 .. d+1, so it grows with the edit distance rather than with the
+// length of the input for every round.
+func lineOps(a, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace from (n, m) back to the start. trace[d][k+d+1]
+// holds the furthest x on diagonal k before round d.
+func backtrack(trace [][]int, n, m int) []op {
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d+1] < v[k+1+d+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{opEqual, x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, op{opInsert, x, y})
+			} else {
+				x--
+				ops = append(ops, op{opDelete, x, y})
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// hunks groups ops into [start, end) ranges of changes with Context lines
+// of unchanged text around them
+func hunks(ops []op) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+		start := i - Context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*Context {
+				end += min(Context, run-end)
+				break
+			}
+			end = run
+		}
+		if len(out) > 0 && start <= out[len(out)-1][1] {
+			out[len(out)-1][1] = end
+		} else {
+			out = append(out, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return out
+}
+
+func writeHunk(sb *strings.Builder, ops []op, a, b []string) {
+	aStart, bStart := ops[0].a, ops[0].b
+	var aLen, bLen int
+	for _, o := range ops {
+		if o.kind != opInsert {
+			aLen++
+		}
+		if o.kind != opDelete {
+			bLen++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			writeLine(sb, " ", a[o.a])
+		case opDelete:
+			writeLine(sb, "-", a[o.a])
+		case opInsert:
+			writeLine(sb, "+", b[o.b])
+		}
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func writeLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// Summary lists module-level differences between two compile orders
+type Summary struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Reordered []string `json:"reordered"`
+}
+
+// Empty reports whether the two orders hold the same modules in the same
+// order
+func (s Summary) Empty() bool {
+	return len(s.Added) == 0 && len(s.Removed) == 0 && len(s.Reordered) == 0
+}
+
+// Modules compares two module orders. IDs only in b are added, IDs only in
+// a are removed, and IDs in both that fall outside their longest common
+// subsequence are reordered. Each list follows the order of the side the
+// IDs come from (b for added and reordered, a for removed).
+func Modules(a, b []string) Summary {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, id := range a {
+		inA[id] = true
+	}
+	for _, id := range b {
+		inB[id] = true
+	}
+
+	s := Summary{Added: []string{}, Removed: []string{}, Reordered: []string{}}
+	var commonA, commonB []string
+	for _, id := range a {
+		if inB[id] {
+			commonA = append(commonA, id)
+		} else {
+			s.Removed = append(s.Removed, id)
+		}
+	}
+	for _, id := range b {
+		if inA[id] {
+			commonB = append(commonB, id)
+		} else {
+			s.Added = append(s.Added, id)
+		}
+	}
+
+	kept := map[string]bool{}
+	for _, o := range lineOps(commonA, commonB) {
+		if o.kind == opEqual {
+			kept[commonA[o.a]] = true
+		}
+	}
+	for _, id := range commonB {
+		if !kept[id] {
+			s.Reordered = append(s.Reordered, id)
+		}
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "--- left\n+++ right\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "append to empty",
+			a:    "",
+			b:    "x\n",
+			want: "--- left\n+++ right\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name: "missing final newline",
+			a:    "a\n",
+			b:    "a\nb",
+			want: "--- left\n+++ right\n@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- left\n+++ right\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("left", "right", tt.a, tt.b); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedMergesNearbyChanges(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n"
+	b := "one\n2\n3\n4\n5\n6\nseven\n"
+	got := Unified("left", "right", a, b)
+	if strings.Count(got, "@@ -") != 1 {
+		t.Errorf("expected a single hunk, got:\n%s", got)
+	}
+}
+
+func TestModules(t *testing.T) {
+	a := []string{"base", "modes/build", "traits/terse", "policies/revisions", "contracts/code"}
+	b := []string{"base", "modes/build", "policies/revisions", "traits/terse", "contracts/markdown"}
+	got := Modules(a, b)
+	want := Summary{
+		Added:     []string{"contracts/markdown"},
+		Removed:   []string{"contracts/code"},
+		Reordered: []string{"traits/terse"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Modules() = %+v, want %+v", got, want)
+	}
+	if !Modules(a, a).Empty() {
+		t.Error("identical orders should produce an empty summary")
+	}
+}
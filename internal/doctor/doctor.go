@@ -7,26 +7,58 @@ import (
 	"strings"
 
 	"github.com/bkuri/ppc/internal/loader"
+	"github.com/bkuri/ppc/internal/model"
 	"github.com/bkuri/ppc/internal/resolver"
+	"github.com/bkuri/ppc/internal/substitute"
 )
 
-// RunDoctor validates module structure and dependencies
-// Returns exit code: 0=ok, 2=failed
-func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool, graphOut bool, outPath string) int {
+// Findings holds the outcome of validating a prompts directory
+type Findings struct {
+	Modules   map[string]*model.Module
+	Rules     *model.Rules
+	Errors    []string
+	Warnings  []string
+	Reachable map[string]bool
+	Stats     *DoctorStats
+}
+
+// Unreachable returns the sorted IDs of modules no entrypoint can reach
+func (f *Findings) Unreachable() []string {
+	var dead []string
+	for id := range f.Modules {
+		if !f.Reachable[id] {
+			dead = append(dead, id)
+		}
+	}
+	sort.Strings(dead)
+	return dead
+}
+
+// Roots returns the IDs of the modules loaded from each prompts root,
+// keyed by root. Overriding modules are listed under the root that won.
+func (f *Findings) Roots() map[string][]string {
+	roots := map[string][]string{}
+	for id, m := range f.Modules {
+		roots[m.Root] = append(roots[m.Root], id)
+	}
+	for _, ids := range roots {
+		sort.Strings(ids)
+	}
+	return roots
+}
+
+// Diagnose runs every doctor check against promptsDir without printing.
+// Load failures are returned as an error; check failures land in Findings.
+// Stats has no token counts until CountTokens is called.
+func Diagnose(promptsDir string) (*Findings, error) {
 	modByID, err := loader.LoadModules(promptsDir)
 	if err != nil {
-		fmt.Println("doctor: FAILED")
-		fmt.Println("errors:")
-		fmt.Printf("  - %v\n", err)
-		return 2
+		return nil, err
 	}
 
 	rules, err := loader.LoadRules(promptsDir)
 	if err != nil {
-		fmt.Println("doctor: FAILED")
-		fmt.Println("errors:")
-		fmt.Printf("  - %v\n", err)
-		return 2
+		return nil, err
 	}
 
 	var errs []string
@@ -57,11 +89,39 @@ func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool
 		}
 	}
 
+	// Validate version constraints on requires
+	for _, e := range resolver.VersionEdges(modByID) {
+		if e.Status != resolver.VersionOK {
+			errs = append(errs, fmt.Sprintf("%s requires: %s", e.Status, e))
+		}
+	}
+
+	// Validate applies_to scopes
+	errs, warns = checkAppliesTo(modByID, errs, warns)
+
+	// Check variable references against vars declarations
+	warns = checkVarRefs(modByID, rules.Substitution.SkipCodeBlocks, warns)
+
+	// Validate suggests and requires_any targets
+	for _, m := range modByID {
+		for _, sg := range m.Front.Suggests {
+			if _, ok := modByID[sg]; !ok {
+				warns = append(warns, fmt.Sprintf("suggests target not found: %s (referenced by %s)", sg, m.Front.ID))
+			}
+		}
+		if len(m.Front.RequiresAny) > 0 && len(resolver.MatchIDs(m.Front.RequiresAny, modByID)) == 0 {
+			errs = append(errs, fmt.Sprintf("requires_any matches no module: %s (referenced by %s)", strings.Join(m.Front.RequiresAny, ", "), m.Front.ID))
+		}
+	}
+
 	// Check for circular dependencies
 	if err := resolver.DetectCycles(modByID); err != nil {
 		errs = append(errs, err.Error())
 	}
 
+	// Validate conflicts
+	errs, warns = checkConflicts(modByID, errs, warns)
+
 	// Validate exclusive groups
 	if len(rules.ExclusiveGroups) == 0 {
 		warns = append(warns, "rules.yml: exclusive_groups is empty")
@@ -94,6 +154,17 @@ func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool
 				mark(r)
 			}
 		}
+		// any requires_any candidate may be the one compiled
+		for _, c := range resolver.MatchIDs(modByID[id].Front.RequiresAny, modByID) {
+			mark(c)
+		}
+		// a suggested module is compiled alongside id once selected, so
+		// it is not an orphan
+		for _, sg := range modByID[id].Front.Suggests {
+			if _, ok := modByID[sg]; ok {
+				mark(sg)
+			}
+		}
 	}
 
 	entryIDs := make([]string, 0, len(entry))
@@ -120,24 +191,200 @@ func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool
 		warns = append(warns, fmt.Sprintf("unreachable modules (%d): %s", len(dead), strings.Join(dead, ", ")))
 	}
 
+	return &Findings{
+		Modules:   modByID,
+		Rules:     rules,
+		Errors:    errs,
+		Warnings:  warns,
+		Reachable: reachable,
+		Stats:     calculateStats(modByID, rules, reachable),
+	}, nil
+}
+
+// CountTokens fills in the token counts of f.Stats
+func (f *Findings) CountTokens() {
+	countTokens(f.Stats, f.Modules)
+}
+
+// checkConflicts validates conflicts targets and reports modules whose
+// requires and requires_any picks pull in two conflicting modules, so they can never be
+// compiled. Each conflicting pair is reported once, for the first such
+// module in ID order.
+func checkConflicts(modByID map[string]*model.Module, errs, warns []string) ([]string, []string) {
+	ids := make([]string, 0, len(modByID))
+	for id := range modByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for _, c := range modByID[id].Front.Conflicts {
+			if c == id {
+				errs = append(errs, fmt.Sprintf("module %s conflicts with itself", id))
+			} else if _, ok := modByID[c]; !ok {
+				warns = append(warns, fmt.Sprintf("conflicts target not found: %s (referenced by %s)", c, id))
+			}
+		}
+	}
+
+	reported := map[string]bool{}
+	for _, id := range ids {
+		chains := moduleChains(id, modByID)
+		closure := make([]string, 0, len(chains))
+		for c := range chains {
+			closure = append(closure, c)
+		}
+		a, b, ok := resolver.FindConflict(closure, modByID)
+		if !ok || reported[a+" "+b] {
+			continue
+		}
+		reported[a+" "+b] = true
+		errs = append(errs, fmt.Sprintf("module %s can never be compiled: its requires pull in conflicting modules %s and %s",
+			id, viaChain(chains[a]), viaChain(chains[b])))
+	}
+	return errs, warns
+}
+
+// moduleChains returns the chains to every module compiled along with id:
+// its requires and the requires_any candidates a compile of id alone
+// would pick. When that compile fails (the cause is reported by the other
+// checks) only requires are followed.
+func moduleChains(id string, modByID map[string]*model.Module) map[string][]string {
+	// ExpandRequires marks id as selected; doctor leaves modules untouched
+	selected := modByID[id].Selected
+	closure, _, err := resolver.ExpandRequires([]string{id}, nil, modByID)
+	modByID[id].Selected = selected
+	if err != nil {
+		closure = nil
+	}
+	return resolver.RequireChains([]string{id}, closure, modByID)
+}
+
+// checkAppliesTo warns about applies_to entries naming unknown modes or
+// contracts, and reports requires edges that can never be satisfied
+// because the target applies to a scope the source is never compiled in
+func checkAppliesTo(modByID map[string]*model.Module, errs, warns []string) ([]string, []string) {
+	ids := make([]string, 0, len(modByID))
+	for id := range modByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		a := modByID[id].Front.AppliesTo
+		for _, d := range []struct {
+			kind, prefix string
+			names        []string
+		}{
+			{"mode", "modes/", a.Modes},
+			{"contract", "contracts/", a.Contracts},
+		} {
+			for _, n := range resolver.AppliesNames(d.names, d.prefix) {
+				if _, ok := modByID[d.prefix+n]; !ok {
+					warns = append(warns, fmt.Sprintf("applies_to names unknown %s: %s (in %s)", d.kind, n, id))
+				}
+			}
+		}
+	}
+
+	for _, id := range ids {
+		scope := resolver.ImpliedScope(modByID[id])
+		reqs := append([]string{}, modByID[id].Front.Requires...)
+		sort.Strings(reqs)
+		for _, r := range reqs {
+			target, ok := modByID[r]
+			if !ok {
+				continue
+			}
+			if dim, allowed, bad := resolver.Unsatisfiable(scope, target.Front.AppliesTo); bad {
+				errs = append(errs, fmt.Sprintf("requires edge can never be satisfied: %s requires %s, which applies_to %s: %s",
+					id, r, dim, strings.Join(allowed, ", ")))
+			}
+		}
+	}
+	return errs, warns
+}
+
+// checkVarRefs warns about {{...}} references that neither the module
+// nor any module it requires declares under vars:, and about raw regions
+// left open. Escaped and verbatim placeholders are not references.
+func checkVarRefs(modByID map[string]*model.Module, skipCode bool, warns []string) []string {
+	ids := make([]string, 0, len(modByID))
+	for id := range modByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var declared []string
+		for dep := range moduleChains(id, modByID) {
+			for name := range modByID[dep].Front.Vars {
+				declared = append(declared, name)
+			}
+		}
+		if _, ok := substitute.Classify(strings.Split(modByID[id].Body, "\n"), skipCode); !ok {
+			warns = append(warns, fmt.Sprintf("unclosed %s region in %s (runs to the end of the body)", substitute.RawOpen, id))
+		}
+		for _, ref := range substitute.BodyPlaceholders(modByID[id].Body, skipCode) {
+			ok := false
+			for _, name := range declared {
+				ok = ok || substitute.Covers(name, ref)
+			}
+			if !ok {
+				warns = append(warns, fmt.Sprintf("undeclared variable: %s (referenced by %s)", ref, id))
+			}
+		}
+	}
+	return warns
+}
+
+// viaChain formats the last module of a requires chain, followed by the
+// chain when it is not the module itself
+func viaChain(chain []string) string {
+	id := chain[len(chain)-1]
+	if len(chain) == 1 {
+		return id
+	}
+	return fmt.Sprintf("%s (via %s)", id, strings.Join(chain, " -> "))
+}
+
+// RunDoctor validates module structure and dependencies
+// Returns exit code: 0=ok, 2=failed
+func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool, graphOut bool, outPath string) int {
+	f, err := Diagnose(promptsDir)
+	if err != nil {
+		fmt.Println("doctor: FAILED")
+		fmt.Println("errors:")
+		fmt.Printf("  - %v\n", err)
+		return 2
+	}
+	modByID, errs, warns := f.Modules, f.Errors, f.Warnings
+
 	// Calculate statistics if requested
 	var stats *DoctorStats
 	if statsRequested {
-		stats = calculateStats(modByID, rules, reachable)
+		f.CountTokens()
+		stats = f.Stats
 	}
 
 	// Output graph if requested (takes precedence)
 	if graphOut {
-		return printDoctorGraph(modByID, rules, reachable, outPath)
+		return printDoctorGraph(modByID, f.Rules, f.Reachable, outPath)
 	}
 
 	// Output results
 	if jsonOut {
-		return printDoctorJSON(len(modByID), errs, warns, strict, stats)
+		var roots map[string][]string
+		if len(loader.Roots(promptsDir)) > 1 {
+			roots = f.Roots()
+		}
+		return printDoctorJSON(len(modByID), errs, warns, strict, stats, roots)
 	}
 
 	if len(errs) == 0 {
 		fmt.Printf("doctor: OK (%d modules)\n", len(modByID))
+		printRoots(loader.Roots(promptsDir), f)
+		printTokens(stats)
 		if len(warns) > 0 {
 			fmt.Println("warnings:")
 			for _, w := range warns {
@@ -152,6 +399,8 @@ func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool
 	}
 
 	fmt.Println("doctor: FAILED")
+	printRoots(loader.Roots(promptsDir), f)
+	printTokens(stats)
 	fmt.Println("errors:")
 	for _, e := range errs {
 		fmt.Println("  - " + e)
@@ -164,3 +413,40 @@ func RunDoctor(promptsDir string, strict bool, jsonOut bool, statsRequested bool
 	}
 	return 2
 }
+
+// printTokens lists the token count of each module and the total, when
+// stats were requested
+func printTokens(stats *DoctorStats) {
+	if stats == nil {
+		return
+	}
+	ids := make([]string, 0, len(stats.ModuleTokens))
+	for id := range stats.ModuleTokens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	fmt.Printf("tokens (%s): %d\n", stats.Encoding, stats.Tokens)
+	for _, id := range ids {
+		fmt.Printf("  - %s: %d\n", id, stats.ModuleTokens[id])
+	}
+}
+
+// printRoots lists the modules each prompts root contributed, marking
+// explicit overrides. Nothing is printed for a single prompts root.
+func printRoots(roots []string, f *Findings) {
+	if len(roots) < 2 {
+		return
+	}
+	byRoot := f.Roots()
+	fmt.Println("roots:")
+	for _, root := range roots {
+		fmt.Printf("  %s:\n", root)
+		for _, id := range byRoot[root] {
+			if f.Modules[id].Front.Overrides != "" {
+				fmt.Printf("    - %s (overrides)\n", id)
+				continue
+			}
+			fmt.Printf("    - %s\n", id)
+		}
+	}
+}
@@ -38,6 +38,104 @@ func TestRunDoctorMissingRequires(t *testing.T) {
 	}
 }
 
+func TestDiagnoseConflicts(t *testing.T) {
+	f, err := Diagnose("testdata/conflicts")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	wantErr := "module modes/build can never be compiled: its requires pull in conflicting modules " +
+		"contracts/code (via modes/build -> contracts/code) and policies/self_score (via modes/build -> policies/self_score)"
+	if len(f.Errors) != 1 || f.Errors[0] != wantErr {
+		t.Errorf("Errors = %q, want [%q]", f.Errors, wantErr)
+	}
+	wantWarn := "conflicts target not found: policies/retired (referenced by policies/self_score)"
+	found := false
+	for _, w := range f.Warnings {
+		found = found || w == wantWarn
+	}
+	if !found {
+		t.Errorf("Warnings = %q, want %q", f.Warnings, wantWarn)
+	}
+}
+
+func TestDiagnoseOptional(t *testing.T) {
+	f, err := Diagnose("testdata/optional")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	wantErr := "requires_any matches no module: policies/missing (referenced by modes/plan)"
+	if len(f.Errors) != 1 || f.Errors[0] != wantErr {
+		t.Errorf("Errors = %q, want [%q]", f.Errors, wantErr)
+	}
+	wantWarn := "suggests target not found: policies/retired (referenced by modes/build)"
+	found := false
+	for _, w := range f.Warnings {
+		found = found || w == wantWarn
+	}
+	if !found {
+		t.Errorf("Warnings = %q, want %q", f.Warnings, wantWarn)
+	}
+	if !f.Reachable["guardrails/tdd"] {
+		t.Error("requires_any candidate guardrails/tdd should be reachable")
+	}
+	if !f.Reachable["policies/review"] {
+		t.Error("suggested policies/review should be reachable")
+	}
+}
+
+func TestDiagnoseVersions(t *testing.T) {
+	f, err := Diagnose("testdata/versions")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	want := []string{
+		"outdated requires: modes/build requires guardrails/tdd >=2.0, found 1.4.0",
+		"incompatible requires: modes/legacy requires guardrails/tdd <1.0, found 1.4.0",
+	}
+	if strings.Join(f.Errors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Errors = %q, want %q", f.Errors, want)
+	}
+}
+
+func TestDiagnoseAppliesTo(t *testing.T) {
+	f, err := Diagnose("testdata/applies")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	wantErr := "requires edge can never be satisfied: modes/explore requires policies/spec_context, which applies_to modes: ship, build"
+	if len(f.Errors) != 1 || f.Errors[0] != wantErr {
+		t.Errorf("Errors = %q, want [%q]", f.Errors, wantErr)
+	}
+	wantWarn := "applies_to names unknown mode: build (in policies/spec_context)"
+	found := false
+	for _, w := range f.Warnings {
+		found = found || w == wantWarn
+	}
+	if !found {
+		t.Errorf("Warnings = %q, want %q", f.Warnings, wantWarn)
+	}
+}
+
+func TestDiagnoseVarRefs(t *testing.T) {
+	f, err := Diagnose("testdata/vars")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	var got []string
+	for _, w := range f.Warnings {
+		if strings.HasPrefix(w, "undeclared variable") || strings.HasPrefix(w, "unclosed") {
+			got = append(got, w)
+		}
+	}
+	want := []string{
+		"unclosed {{raw}} region in base (runs to the end of the body)",
+		"undeclared variable: deadline (referenced by modes/build)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("variable warnings = %q, want %q", got, want)
+	}
+}
+
 func TestRunDoctorCircular(t *testing.T) {
 	exitCode := captureOutput(func() int {
 		return RunDoctor("testdata/circular", false, false, false, false, "")
@@ -151,6 +249,25 @@ func TestRunDoctorStats(t *testing.T) {
 	if report.Stats.ByLayer["modes"] != 1 {
 		t.Errorf("expected 1 modes module, got %d", report.Stats.ByLayer["modes"])
 	}
+
+	if report.Stats.Tokens == 0 || len(report.Stats.ModuleTokens) != 3 || report.Stats.Encoding == "" {
+		t.Errorf("tokens = %d, module_tokens = %v, encoding = %q",
+			report.Stats.Tokens, report.Stats.ModuleTokens, report.Stats.Encoding)
+	}
+}
+
+func TestRunDoctorStatsText(t *testing.T) {
+	var output bytes.Buffer
+	exitCode := captureOutputTo(&output, func() int {
+		return RunDoctor("testdata/valid", false, false, true, false, "")
+	})
+
+	if exitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", exitCode)
+	}
+	if !strings.Contains(output.String(), "tokens (") || !strings.Contains(output.String(), "  - base: ") {
+		t.Errorf("expected token counts in output, got:\n%s", output.String())
+	}
 }
 
 func TestCalculateStats(t *testing.T) {
@@ -184,6 +301,21 @@ func TestCalculateStats(t *testing.T) {
 	}
 }
 
+func TestDiagnoseCountTokens(t *testing.T) {
+	f, err := Diagnose("testdata/valid")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	if f.Stats.Tokens != 0 || f.Stats.ModuleTokens != nil {
+		t.Errorf("Diagnose should not count tokens, got %d (%v)", f.Stats.Tokens, f.Stats.ModuleTokens)
+	}
+	f.CountTokens()
+	if f.Stats.Tokens == 0 || len(f.Stats.ModuleTokens) != 3 || f.Stats.Encoding == "" {
+		t.Errorf("tokens = %d, module_tokens = %v, encoding = %q",
+			f.Stats.Tokens, f.Stats.ModuleTokens, f.Stats.Encoding)
+	}
+}
+
 func TestLayerNameFromIndex(t *testing.T) {
 	tests := []struct {
 		idx      int
@@ -227,3 +359,43 @@ func captureOutputTo(buf *bytes.Buffer, fn func() int) int {
 
 	return exitCode
 }
+
+func TestDiagnoseUnreachable(t *testing.T) {
+	f, err := Diagnose("testdata/unreachable")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+
+	if len(f.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", f.Errors)
+	}
+
+	dead := f.Unreachable()
+	if len(dead) != 1 || dead[0] != "traits/orphan" {
+		t.Errorf("Unreachable() = %v, want [traits/orphan]", dead)
+	}
+}
+
+func TestDiagnoseLoadError(t *testing.T) {
+	if _, err := Diagnose("testdata/nonexistent"); err == nil {
+		t.Fatal("expected error for missing rules.yml")
+	}
+}
+
+func TestDiagnoseRoots(t *testing.T) {
+	f, err := Diagnose("testdata/unreachable" + string(os.PathListSeparator) + "testdata/overlay")
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+
+	roots := f.Roots()
+	overlay := roots["testdata/overlay"]
+	if len(overlay) != 1 || overlay[0] != "traits/orphan" {
+		t.Errorf("overlay root = %v, want [traits/orphan]", overlay)
+	}
+	for _, id := range roots["testdata/unreachable"] {
+		if id == "traits/orphan" {
+			t.Error("overridden module still listed under its original root")
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/bkuri/ppc/internal/model"
 	"github.com/bkuri/ppc/internal/resolver"
+	"github.com/bkuri/ppc/internal/tokens"
 )
 
 // DoctorStats represents module statistics
@@ -17,6 +18,11 @@ type DoctorStats struct {
 	Tags        int            `json:"tags"`
 	Groups      int            `json:"groups"`
 	Orphaned    int            `json:"orphaned"`
+	// Tokens is the token count of all module bodies in Encoding;
+	// ModuleTokens breaks it down by module ID
+	Tokens       int            `json:"tokens"`
+	ModuleTokens map[string]int `json:"module_tokens"`
+	Encoding     string         `json:"encoding"`
 }
 
 // DoctorReport represents the complete doctor report
@@ -26,11 +32,14 @@ type DoctorReport struct {
 	Errors   []string     `json:"errors,omitempty"`
 	Warnings []string     `json:"warnings,omitempty"`
 	Stats    *DoctorStats `json:"stats,omitempty"`
+	// Roots maps each prompts root to the module IDs loaded from it; it is
+	// only set when several roots are layered
+	Roots map[string][]string `json:"roots,omitempty"`
 }
 
 // printDoctorJSON outputs doctor results as JSON
 // Returns exit code: 0=ok, 2=failed
-func printDoctorJSON(moduleCount int, errs, warns []string, strict bool, stats *DoctorStats) int {
+func printDoctorJSON(moduleCount int, errs, warns []string, strict bool, stats *DoctorStats, roots map[string][]string) int {
 	status := "ok"
 	exitCode := 0
 
@@ -48,6 +57,7 @@ func printDoctorJSON(moduleCount int, errs, warns []string, strict bool, stats *
 		Errors:   errs,
 		Warnings: warns,
 		Stats:    stats,
+		Roots:    roots,
 	}
 
 	b, err := json.MarshalIndent(report, "", "  ")
@@ -60,12 +70,16 @@ func printDoctorJSON(moduleCount int, errs, warns []string, strict bool, stats *
 	return exitCode
 }
 
-// calculateStats computes module statistics
+// calculateStats computes module statistics, without token counts
 func calculateStats(modByID map[string]*model.Module, rules *model.Rules, reachable map[string]bool) *DoctorStats {
-	byLayer := map[string]int{"base": 0, "modes": 0, "traits": 0, "policies": 0, "contracts": 0}
+	order := rules.LayerOrder()
+	byLayer := map[string]int{}
+	for _, l := range order {
+		byLayer[l] = 0
+	}
 
 	for _, m := range modByID {
-		layerName := model.LayerName(m.Layer)
+		layerName := model.LayerNameIn(order, m.Layer)
 		byLayer[layerName]++
 	}
 
@@ -103,3 +117,16 @@ func calculateStats(modByID map[string]*model.Module, rules *model.Rules, reacha
 		Orphaned:    orphaned,
 	}
 }
+
+// countTokens sets the token counts of stats from the module bodies.
+// Encoding every module is costly, so it only runs when stats are shown.
+func countTokens(stats *DoctorStats, modByID map[string]*model.Module) {
+	enc := tokens.Default()
+	stats.Tokens = 0
+	stats.ModuleTokens = map[string]int{}
+	for id, m := range modByID {
+		stats.ModuleTokens[id] = enc.Count(m.Body)
+		stats.Tokens += stats.ModuleTokens[id]
+	}
+	stats.Encoding = enc.Name()
+}
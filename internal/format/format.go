@@ -0,0 +1,189 @@
+// Package format encodes a compiled prompt for the consumer that loads it.
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bkuri/ppc/internal/compile"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	Markdown        = "markdown"
+	JSON            = "json"
+	YAML            = "yaml"
+	OpenAIMessages  = "openai-messages"
+	AnthropicSystem = "anthropic-system"
+)
+
+// Names lists the supported formats
+var Names = []string{Markdown, JSON, YAML, OpenAIMessages, AnthropicSystem}
+
+// Segment is one rendered module in the json and yaml formats
+type Segment struct {
+	ID     string `json:"id" yaml:"id"`
+	Layer  string `json:"layer" yaml:"layer"`
+	Path   string `json:"path" yaml:"path"`
+	Body   string `json:"body" yaml:"body"`
+	Tokens int    `json:"tokens" yaml:"tokens"`
+}
+
+// Source is a module source file in the json and yaml formats
+type Source struct {
+	ID      string `json:"id" yaml:"id"`
+	Path    string `json:"path" yaml:"path"`
+	Hash    string `json:"sha256" yaml:"sha256"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// Unresolved is an unsubstituted placeholder in the json and yaml formats
+type Unresolved struct {
+	Name   string `json:"name" yaml:"name"`
+	Module string `json:"module" yaml:"module"`
+	Path   string `json:"path" yaml:"path"`
+	Line   int    `json:"line" yaml:"line"`
+}
+
+// Meta describes the compilation in the json and yaml formats
+type Meta struct {
+	Hash           string       `json:"hash" yaml:"hash"`
+	SelectedIDs    []string     `json:"selected_ids" yaml:"selected_ids"`
+	ClosureIDs     []string     `json:"closure_ids" yaml:"closure_ids"`
+	Order          []string     `json:"order" yaml:"order"`
+	UnresolvedVars []Unresolved `json:"unresolved_vars" yaml:"unresolved_vars"`
+	RulesHash      string       `json:"rules_hash" yaml:"rules_hash"`
+	Sources        []Source     `json:"sources" yaml:"sources"`
+	Tokens         int          `json:"tokens" yaml:"tokens"`
+	Encoding       string       `json:"encoding" yaml:"encoding"`
+	VarSources     []VarSource  `json:"var_sources" yaml:"var_sources"`
+	Provenance     *Provenance  `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+}
+
+// Provenance is the part of the compiled-from header that is not already
+// in Meta (rules_hash and sources), set when provenance is requested
+type Provenance struct {
+	PPC     string `json:"ppc" yaml:"ppc"`
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+}
+
+// VarSource is the source of a variable value: file:PATH, profile,
+// env:NAME, var or default:MODULE
+type VarSource struct {
+	Name   string `json:"name" yaml:"name"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// Document is the json and yaml output
+type Document struct {
+	Segments []Segment `json:"segments" yaml:"segments"`
+	Meta     Meta      `json:"meta" yaml:"meta"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Validate reports an error for unknown format names
+func Validate(name string) error {
+	for _, n := range Names {
+		if n == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(Names, ", "))
+}
+
+// Encode renders a compiled prompt in the named format. prompt is the
+// Markdown text, including any headers; it becomes the system message in
+// the chat formats. The json and yaml formats carry the segments and meta
+// instead of the headers: meta.hash is the prompt-id hash, and prov, when
+// not nil, becomes meta.provenance next to rules_hash and sources.
+func Encode(name, prompt string, meta compile.CompileMeta, prov *Provenance) (string, error) {
+	switch name {
+	case "", Markdown:
+		return prompt, nil
+	case JSON:
+		doc := NewDocument(meta)
+		doc.Meta.Provenance = prov
+		return encodeJSON(doc)
+	case YAML:
+		doc := NewDocument(meta)
+		doc.Meta.Provenance = prov
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	case OpenAIMessages:
+		return encodeJSON(map[string][]openAIMessage{
+			"messages": {{Role: "system", Content: prompt}},
+		})
+	case AnthropicSystem:
+		return encodeJSON(map[string][]anthropicBlock{
+			"system": {{Type: "text", Text: prompt}},
+		})
+	}
+	return "", Validate(name)
+}
+
+// NewDocument builds the json and yaml document for meta
+func NewDocument(meta compile.CompileMeta) Document {
+	doc := Document{
+		Segments: make([]Segment, 0, len(meta.Segments)),
+		Meta: Meta{
+			Hash:           meta.Hash,
+			SelectedIDs:    nonNil(meta.SelectedIDs),
+			ClosureIDs:     nonNil(meta.ClosureIDs),
+			Order:          nonNil(meta.Order),
+			UnresolvedVars: make([]Unresolved, 0, len(meta.UnresolvedVars)),
+			RulesHash:      meta.RulesHash,
+			Sources:        make([]Source, 0, len(meta.Sources)),
+			Tokens:         meta.Tokens,
+			Encoding:       meta.Encoding,
+			VarSources:     make([]VarSource, 0, len(meta.VarSources)),
+		},
+	}
+	for _, s := range meta.Segments {
+		doc.Segments = append(doc.Segments, Segment{ID: s.ID, Layer: s.Layer, Path: s.Path, Body: s.Body, Tokens: s.Tokens})
+	}
+	for _, u := range meta.UnresolvedVars {
+		doc.Meta.UnresolvedVars = append(doc.Meta.UnresolvedVars, Unresolved(u))
+	}
+	for _, v := range meta.VarSources {
+		doc.Meta.VarSources = append(doc.Meta.VarSources, VarSource(v))
+	}
+	for _, s := range meta.Sources {
+		doc.Meta.Sources = append(doc.Meta.Sources, Source{ID: s.ID, Path: s.Path, Hash: s.Hash, Version: s.Version})
+	}
+	return doc
+}
+
+func encodeJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
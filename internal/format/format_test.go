@@ -0,0 +1,140 @@
+package format
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bkuri/ppc/internal/compile"
+	"gopkg.in/yaml.v3"
+)
+
+func testMeta() compile.CompileMeta {
+	return compile.CompileMeta{
+		SelectedIDs: []string{"base", "modes/build"},
+		ClosureIDs:  []string{"base", "modes/build"},
+		Order:       []string{"base", "modes/build"},
+		Hash:        "abc123",
+		Sources: []compile.ModuleSource{
+			{ID: "base", Path: "prompts/base.md", Hash: "h1"},
+			{ID: "modes/build", Path: "prompts/modes/build.md", Hash: "h2"},
+		},
+		Segments: []compile.Segment{
+			{ID: "base", Layer: "base", Path: "prompts/base.md", Body: "Base <rules> & more."},
+			{ID: "modes/build", Layer: "modes", Path: "prompts/modes/build.md", Body: "Build."},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	prompt := "Base <rules> & more.\n\nBuild.\n"
+
+	t.Run("markdown", func(t *testing.T) {
+		out, err := Encode(Markdown, prompt, testMeta(), nil)
+		if err != nil || out != prompt {
+			t.Errorf("Encode(markdown) = %q, %v; want prompt unchanged", out, err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := Encode(JSON, prompt, testMeta(), nil)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		var doc Document
+		if err := json.Unmarshal([]byte(out), &doc); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, out)
+		}
+		if len(doc.Segments) != 2 || doc.Segments[1].Layer != "modes" {
+			t.Errorf("segments = %+v", doc.Segments)
+		}
+		if doc.Segments[0].Body != "Base <rules> & more." {
+			t.Errorf("body = %q, want HTML left unescaped", doc.Segments[0].Body)
+		}
+		if doc.Meta.Hash != "abc123" || len(doc.Meta.Sources) != 2 {
+			t.Errorf("meta = %+v", doc.Meta)
+		}
+		if !strings.Contains(out, `"unresolved_vars": []`) {
+			t.Errorf("empty lists should encode as [], got:\n%s", out)
+		}
+		if strings.Contains(out, "provenance") {
+			t.Errorf("provenance should be omitted when not requested, got:\n%s", out)
+		}
+	})
+
+	t.Run("provenance", func(t *testing.T) {
+		prov := &Provenance{PPC: "0.6.0", Profile: "ship"}
+		for _, name := range []string{JSON, YAML} {
+			out, err := Encode(name, prompt, testMeta(), prov)
+			if err != nil {
+				t.Fatalf("Encode(%s) failed: %v", name, err)
+			}
+			var doc Document
+			if err := yaml.Unmarshal([]byte(out), &doc); err != nil {
+				t.Fatalf("invalid %s: %v\n%s", name, err, out)
+			}
+			if doc.Meta.Provenance == nil || *doc.Meta.Provenance != *prov {
+				t.Errorf("%s meta.provenance = %+v, want %+v", name, doc.Meta.Provenance, prov)
+			}
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		out, err := Encode(YAML, prompt, testMeta(), nil)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		var doc Document
+		if err := yaml.Unmarshal([]byte(out), &doc); err != nil {
+			t.Fatalf("invalid YAML: %v\n%s", err, out)
+		}
+		if len(doc.Segments) != 2 || doc.Segments[0].ID != "base" {
+			t.Errorf("segments = %+v", doc.Segments)
+		}
+	})
+
+	t.Run("openai-messages", func(t *testing.T) {
+		out, err := Encode(OpenAIMessages, prompt, testMeta(), nil)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		var payload struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal([]byte(out), &payload); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(payload.Messages) != 1 || payload.Messages[0].Role != "system" || payload.Messages[0].Content != prompt {
+			t.Errorf("messages = %+v", payload.Messages)
+		}
+	})
+
+	t.Run("anthropic-system", func(t *testing.T) {
+		out, err := Encode(AnthropicSystem, prompt, testMeta(), nil)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		var payload struct {
+			System []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"system"`
+		}
+		if err := json.Unmarshal([]byte(out), &payload); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(payload.System) != 1 || payload.System[0].Type != "text" || payload.System[0].Text != prompt {
+			t.Errorf("system = %+v", payload.System)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := Encode("toml", prompt, testMeta(), nil)
+		if err == nil || !strings.Contains(err.Error(), "available:") {
+			t.Errorf("Encode(toml) error = %v, want unknown format with list", err)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package gitref
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	base := filepath.Join(repo, "sub", "prompts", "base.md")
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(base, []byte("old\n"), 0o644)
+	run("init", "-q")
+	run("add", ".")
+	run("commit", "-qm", "old")
+	run("tag", "v1")
+	os.WriteFile(base, []byte("new\n"), 0o644)
+
+	dst := t.TempDir()
+	dir, err := Export(filepath.Join(repo, "sub"), "v1", dst)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if dir != filepath.Join(dst, "sub") {
+		t.Errorf("dir = %s, want %s", dir, filepath.Join(dst, "sub"))
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "prompts", "base.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old\n" {
+		t.Errorf("exported base.md = %q, want content at v1", b)
+	}
+
+	if _, err := Export(repo, "no-such-ref", t.TempDir()); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+	if _, err := Export(repo, "--output=x", t.TempDir()); err == nil || !strings.Contains(err.Error(), "must not start with -") {
+		t.Errorf("Export = %v, want invalid ref error", err)
+	}
+}
@@ -6,11 +6,20 @@ import (
 	"strings"
 
 	"github.com/bkuri/ppc/internal/model"
+	"github.com/bkuri/ppc/internal/resolver"
 )
 
-// Edge represents a directed edge in the dependency graph
+// Edge represents a directed edge in the dependency graph. Kind is
+// empty for requires, or resolver.Suggests / resolver.RequiresAny.
 type Edge struct {
 	From, To string
+	Kind     string
+}
+
+// edgeStyles holds the DOT style of each optional edge kind
+var edgeStyles = map[string]string{
+	resolver.Suggests:    "dashed",
+	resolver.RequiresAny: "dotted",
 }
 
 // BuildDOT generates Graphviz DOT representation of module dependency graph.
@@ -21,8 +30,12 @@ type Edge struct {
 // - modules sorted by (layer, id)
 // - edges sorted lexicographically (source, then target)
 // - attribute ordering consistent (shape, style, color)
+// - suggests edges dashed (existing targets only), requires_any dotted
 // - subgraph names stable (cluster_0_base, cluster_1_modes, etc.)
-func BuildDOT(modByID map[string]*model.Module, _ *model.Rules, reachable map[string]bool) string {
+//
+// Clusters follow the layer order declared in rules (default model.LayerOrder).
+func BuildDOT(modByID map[string]*model.Module, rules *model.Rules, reachable map[string]bool) string {
+	order := rules.LayerOrder()
 	sortedIDs := sortedModuleIDs(modByID)
 	byLayer := layerSubgraphs(sortedIDs, modByID)
 	edges := collectEdges(sortedIDs, modByID)
@@ -31,10 +44,10 @@ func BuildDOT(modByID map[string]*model.Module, _ *model.Rules, reachable map[st
 	buf.WriteString("digraph ppc {\n")
 	buf.WriteString("  rankdir=LR;\n\n")
 
-	for layerIdx := 0; layerIdx < len(model.LayerOrder); layerIdx++ {
+	for layerIdx := 0; layerIdx < len(order); layerIdx++ {
 		if ids, ok := byLayer[layerIdx]; ok && len(ids) > 0 {
-			clusterName := fmt.Sprintf("cluster_%d_%s", layerIdx, model.LayerName(layerIdx))
-			layerLabel := model.LayerName(layerIdx)
+			clusterName := fmt.Sprintf("cluster_%d_%s", layerIdx, model.LayerNameIn(order, layerIdx))
+			layerLabel := model.LayerNameIn(order, layerIdx)
 			buf.WriteString(fmt.Sprintf("  subgraph %s {\n", clusterName))
 			buf.WriteString(fmt.Sprintf("    label=\"%s\";\n", layerLabel))
 
@@ -46,7 +59,11 @@ func BuildDOT(modByID map[string]*model.Module, _ *model.Rules, reachable map[st
 	}
 
 	for _, edge := range edges {
-		buf.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\";\n", edge.From, edge.To))
+		if style, ok := edgeStyles[edge.Kind]; ok {
+			buf.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\" [style=\"%s\"];\n", edge.From, edge.To, style))
+		} else {
+			buf.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\";\n", edge.From, edge.To))
+		}
 	}
 
 	buf.WriteString("\n")
@@ -96,22 +113,39 @@ func collectEdges(sortedIDs []string, modByID map[string]*model.Module) []Edge {
 	var edges []Edge
 	seen := make(map[string]bool)
 
+	add := func(from, to, kind string) {
+		edgeKey := from + "->" + to + " " + kind
+		if !seen[edgeKey] {
+			edges = append(edges, Edge{From: from, To: to, Kind: kind})
+			seen[edgeKey] = true
+		}
+	}
+
 	for _, id := range sortedIDs {
 		m := modByID[id]
 		for _, req := range m.Front.Requires {
-			edgeKey := id + "->" + req
-			if !seen[edgeKey] {
-				edges = append(edges, Edge{From: id, To: req})
-				seen[edgeKey] = true
+			add(id, req, "")
+		}
+		// missing suggests targets are only a doctor warning; drawing
+		// them would add nodes for modules that do not exist
+		for _, s := range m.Front.Suggests {
+			if _, ok := modByID[s]; ok {
+				add(id, s, resolver.Suggests)
 			}
 		}
+		for _, c := range resolver.MatchIDs(m.Front.RequiresAny, modByID) {
+			add(id, c, resolver.RequiresAny)
+		}
 	}
 
 	sort.Slice(edges, func(i, j int) bool {
 		if edges[i].From != edges[j].From {
 			return edges[i].From < edges[j].From
 		}
-		return edges[i].To < edges[j].To
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Kind < edges[j].Kind
 	})
 
 	return edges
@@ -0,0 +1,22 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bkuri/ppc/internal/model"
+)
+
+func TestBuildDOTSkipsMissingSuggests(t *testing.T) {
+	modByID := map[string]*model.Module{
+		"base":            {Front: model.Frontmatter{ID: "base", Suggests: []string{"policies/review", "policies/retired"}}},
+		"policies/review": {Front: model.Frontmatter{ID: "policies/review"}, Layer: 4},
+	}
+	dot := BuildDOT(modByID, &model.Rules{}, map[string]bool{"base": true, "policies/review": true})
+	if !strings.Contains(dot, `"base" -> "policies/review"`) {
+		t.Errorf("expected suggests edge to policies/review, got:\n%s", dot)
+	}
+	if strings.Contains(dot, "policies/retired") {
+		t.Errorf("missing suggests target should not be drawn, got:\n%s", dot)
+	}
+}
@@ -4,24 +4,73 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/bkuri/ppc/internal/loader"
 	"github.com/bkuri/ppc/internal/model"
+	"github.com/bkuri/ppc/internal/tokens"
+	"github.com/bkuri/ppc/internal/vendoring"
 )
 
+// Severity levels, from most to least severe
+const (
+	LevelError = "ERROR"
+	LevelWarn  = "WARN"
+	LevelInfo  = "INFO"
+)
+
+// FailNone disables failing on violations of any severity
+const FailNone = "none"
+
+var levelRank = map[string]int{LevelInfo: 1, LevelWarn: 2, LevelError: 3}
+
+// BuiltinRules lists every lint rule name accepted in severity maps
+var BuiltinRules = []string{
+	"max_words", "max_lines", "max_modules", "max_module_words", "max_depth", "max_tokens",
+	"require_tags", "forbid_tags", "require_fields", "forbid_empty_body", "forbid_content",
+}
+
 type Config struct {
 	MaxWords              int
 	MaxLines              int
 	MaxModules            int
 	MaxModuleWords        int
 	MaxDepth              int
+	MaxTokens             int
 	RequireTags           []string
 	ForbidTags            []string
 	RequireFields         []string
 	ForbidEmptyBody       bool
 	ForbidContentPatterns []ContentPattern
+	Scopes                []Scope
+	// Severity maps a rule name to error, warn or info (default warn)
+	Severity map[string]string
+	// FailOn is the lowest severity that fails the run: error, warn,
+	// info or none (default warn)
+	FailOn string
+}
+
+// Scope overrides per-module rules for modules matching Paths.
+// Nil or empty fields inherit the global setting.
+type Scope struct {
+	Name            string
+	Paths           []string
+	MaxModuleWords  *int
+	ForbidEmptyBody *bool
+	RequireFields   []string
+	ForbidTags      []string
+	ContentPatterns []ContentPattern
+	Severity        map[string]string
+}
+
+// Label identifies the scope in violations: its name, or its paths
+func (s Scope) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strings.Join(s.Paths, ",")
 }
 
 type CLISet struct {
@@ -30,12 +79,14 @@ type CLISet struct {
 	MaxModules     bool
 	MaxModuleWords bool
 	MaxDepth       bool
+	MaxTokens      bool
 }
 
 type ContentPattern struct {
 	Match  string
 	Reason string
 	Paths  []string
+	Scope  string
 }
 
 type Violation struct {
@@ -43,11 +94,18 @@ type Violation struct {
 	Rule    string `json:"rule"`
 	Message string `json:"message"`
 	Module  string `json:"module,omitempty"`
+	Scope   string `json:"scope,omitempty"`
 }
 
 type Result struct {
 	Violations []Violation    `json:"violations"`
+	Counts     map[string]int `json:"counts"`
+	Failed     bool           `json:"failed"`
 	Stats      map[string]int `json:"stats"`
+	// Tokens maps each module ID to the token count of its body in
+	// Encoding
+	Tokens   map[string]int `json:"tokens"`
+	Encoding string         `json:"encoding"`
 }
 
 func MergeConfig(file model.LintConfig, cli Config, cliSet CLISet) Config {
@@ -57,6 +115,7 @@ func MergeConfig(file model.LintConfig, cli Config, cliSet CLISet) Config {
 		MaxModules:      coalesceInt(file.MaxModules, cli.MaxModules, cliSet.MaxModules),
 		MaxModuleWords:  coalesceInt(file.MaxModuleWords, cli.MaxModuleWords, cliSet.MaxModuleWords),
 		MaxDepth:        coalesceInt(file.MaxDepth, cli.MaxDepth, cliSet.MaxDepth),
+		MaxTokens:       coalesceInt(file.MaxTokens, cli.MaxTokens, cliSet.MaxTokens),
 		ForbidEmptyBody: coalesceBool(file.ForbidEmptyBody, cli.ForbidEmptyBody),
 	}
 
@@ -81,18 +140,45 @@ func MergeConfig(file model.LintConfig, cli Config, cliSet CLISet) Config {
 	if len(cli.ForbidContentPatterns) > 0 {
 		merged.ForbidContentPatterns = cli.ForbidContentPatterns
 	} else if len(file.ForbidContentPatterns) > 0 {
-		for _, p := range file.ForbidContentPatterns {
-			merged.ForbidContentPatterns = append(merged.ForbidContentPatterns, ContentPattern{
-				Match:  p.Match,
-				Reason: p.Reason,
-				Paths:  p.Paths,
-			})
+		merged.ForbidContentPatterns = convertPatterns(file.ForbidContentPatterns, "")
+	}
+
+	merged.Severity = file.Severity
+	merged.FailOn = file.FailOn
+	if cli.FailOn != "" {
+		merged.FailOn = cli.FailOn
+	}
+
+	for _, s := range file.Scopes {
+		scope := Scope{
+			Name:            s.Name,
+			Paths:           s.Paths,
+			MaxModuleWords:  s.MaxModuleWords,
+			ForbidEmptyBody: s.ForbidEmpty,
+			RequireFields:   s.RequireFields,
+			ForbidTags:      s.ForbidTags,
+			Severity:        s.Severity,
 		}
+		scope.ContentPatterns = convertPatterns(s.ContentPattern, scope.Label())
+		merged.Scopes = append(merged.Scopes, scope)
 	}
 
 	return merged
 }
 
+func convertPatterns(patterns []model.LintContentPattern, scope string) []ContentPattern {
+	var out []ContentPattern
+	for _, p := range patterns {
+		out = append(out, ContentPattern{
+			Match:  p.Match,
+			Reason: p.Reason,
+			Paths:  p.Paths,
+			Scope:  scope,
+		})
+	}
+	return out
+}
+
 func coalesceInt(file, cli int, cliSet bool) int {
 	if cliSet {
 		return cli
@@ -107,41 +193,128 @@ func coalesceBool(file, cli bool) bool {
 	return file
 }
 
+// Validate checks severity names, severity rule names and the fail-on threshold
+func (c Config) Validate() error {
+	if _, err := failRank(c.FailOn); err != nil {
+		return err
+	}
+	if err := validateSeverity(c.Severity, "severity"); err != nil {
+		return err
+	}
+	for _, s := range c.Scopes {
+		if err := validateSeverity(s.Severity, fmt.Sprintf("scope %q severity", s.Label())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSeverity(sev map[string]string, where string) error {
+	rules := make([]string, 0, len(sev))
+	for r := range sev {
+		rules = append(rules, r)
+	}
+	sort.Strings(rules)
+	for _, r := range rules {
+		if !slices.Contains(BuiltinRules, r) {
+			return fmt.Errorf("%s: unknown rule %q (expected one of: %s)", where, r, strings.Join(BuiltinRules, ", "))
+		}
+		if _, ok := levelRank[strings.ToUpper(sev[r])]; !ok {
+			return fmt.Errorf("%s: rule %q has invalid level %q (expected error, warn or info)", where, r, sev[r])
+		}
+	}
+	return nil
+}
+
+// failRank returns the minimum level rank that fails a run, or 0 for none
+func failRank(failOn string) (int, error) {
+	switch strings.ToLower(failOn) {
+	case "":
+		return levelRank[LevelWarn], nil
+	case FailNone:
+		return 0, nil
+	}
+	rank, ok := levelRank[strings.ToUpper(failOn)]
+	if !ok {
+		return 0, fmt.Errorf("invalid fail-on level %q (expected error, warn, info or none)", failOn)
+	}
+	return rank, nil
+}
+
+func (c Config) level(rule string) string {
+	return levelFor(rule, c.Severity)
+}
+
+func levelFor(rule string, severity map[string]string) string {
+	if lvl, ok := severity[rule]; ok {
+		return strings.ToUpper(lvl)
+	}
+	return LevelWarn
+}
+
 func Run(promptsDir string, cfg Config) (*Result, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	modByID, err := loader.LoadModules(promptsDir)
 	if err != nil {
 		return nil, err
 	}
 
+	enc := tokens.Default()
 	result := &Result{
 		Violations: []Violation{},
 		Stats:      make(map[string]int),
+		Tokens:     make(map[string]int),
+		Encoding:   enc.Name(),
 	}
 
 	result.Stats["module_count"] = len(modByID)
 
+	order, _, err := loader.LayerOrder(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range order {
+		result.Stats["layer_"+l] = 0
+	}
+	for _, m := range modByID {
+		result.Stats["layer_"+model.LayerNameIn(order, m.Layer)]++
+	}
+
 	totalWords := 0
 	totalLines := 0
+	totalTokens := 0
 	maxModuleWords := 0
+	maxModuleTokens := 0
 
-	for _, m := range modByID {
+	for id, m := range modByID {
 		words := countWords(m.Body)
 		lines := countLines(m.Body)
+		toks := enc.Count(m.Body)
 		totalWords += words
 		totalLines += lines
+		totalTokens += toks
+		result.Tokens[id] = toks
 		if words > maxModuleWords {
 			maxModuleWords = words
 		}
+		if toks > maxModuleTokens {
+			maxModuleTokens = toks
+		}
 	}
 
 	result.Stats["word_count"] = totalWords
 	result.Stats["line_count"] = totalLines
 	result.Stats["max_module_words"] = maxModuleWords
+	result.Stats["token_count"] = totalTokens
+	result.Stats["max_module_tokens"] = maxModuleTokens
 
 	if cfg.MaxWords > 0 && totalWords > cfg.MaxWords {
 		pct := percentOver(totalWords, cfg.MaxWords)
 		result.Violations = append(result.Violations, Violation{
-			Level:   "WARN",
+			Level:   cfg.level("max_words"),
 			Rule:    "max_words",
 			Message: fmt.Sprintf("word count (%d) exceeds threshold (%d) by %d%%", totalWords, cfg.MaxWords, pct),
 		})
@@ -150,16 +323,25 @@ func Run(promptsDir string, cfg Config) (*Result, error) {
 	if cfg.MaxLines > 0 && totalLines > cfg.MaxLines {
 		pct := percentOver(totalLines, cfg.MaxLines)
 		result.Violations = append(result.Violations, Violation{
-			Level:   "WARN",
+			Level:   cfg.level("max_lines"),
 			Rule:    "max_lines",
 			Message: fmt.Sprintf("line count (%d) exceeds threshold (%d) by %d%%", totalLines, cfg.MaxLines, pct),
 		})
 	}
 
+	if cfg.MaxTokens > 0 && totalTokens > cfg.MaxTokens {
+		pct := percentOver(totalTokens, cfg.MaxTokens)
+		result.Violations = append(result.Violations, Violation{
+			Level:   cfg.level("max_tokens"),
+			Rule:    "max_tokens",
+			Message: fmt.Sprintf("token count (%d, %s) exceeds threshold (%d) by %d%%", totalTokens, enc.Name(), cfg.MaxTokens, pct),
+		})
+	}
+
 	if cfg.MaxModules > 0 && len(modByID) > cfg.MaxModules {
 		pct := percentOver(len(modByID), cfg.MaxModules)
 		result.Violations = append(result.Violations, Violation{
-			Level:   "WARN",
+			Level:   cfg.level("max_modules"),
 			Rule:    "max_modules",
 			Message: fmt.Sprintf("module count (%d) exceeds threshold (%d) by %d%%", len(modByID), cfg.MaxModules, pct),
 		})
@@ -173,7 +355,7 @@ func Run(promptsDir string, cfg Config) (*Result, error) {
 		for _, pattern := range cfg.RequireTags {
 			if !tagPatternMatches(pattern, allTags) {
 				result.Violations = append(result.Violations, Violation{
-					Level:   "WARN",
+					Level:   cfg.level("require_tags"),
 					Rule:    "require_tags",
 					Message: "no module has required tag pattern '" + pattern + "'",
 				})
@@ -186,7 +368,7 @@ func Run(promptsDir string, cfg Config) (*Result, error) {
 			depth, chain := calculateModuleDepth(modByID, id)
 			if depth > cfg.MaxDepth {
 				result.Violations = append(result.Violations, Violation{
-					Level:   "WARN",
+					Level:   cfg.level("max_depth"),
 					Rule:    "max_depth",
 					Message: formatDepthMessage(depth, cfg.MaxDepth, chain),
 					Module:  id,
@@ -203,37 +385,41 @@ func Run(promptsDir string, cfg Config) (*Result, error) {
 
 	for _, id := range sortedIDs {
 		m := modByID[id]
-		scope := resolveScope(m.Path, cfg)
+		relPath := relModulePath(m.Root, m.Path)
+		scope := resolveScope(m.Path, relPath, cfg)
 
 		if scope.MaxModuleWords > 0 {
 			words := countWords(m.Body)
 			if words > scope.MaxModuleWords {
 				pct := percentOver(words, scope.MaxModuleWords)
 				result.Violations = append(result.Violations, Violation{
-					Level:   "WARN",
+					Level:   scope.level("max_module_words"),
 					Rule:    "max_module_words",
 					Message: fmt.Sprintf("word count (%d) exceeds threshold (%d) by %d%%", words, scope.MaxModuleWords, pct),
 					Module:  id,
+					Scope:   scope.Origin["max_module_words"],
 				})
 			}
 		}
 
 		if scope.ForbidEmptyBody && strings.TrimSpace(m.Body) == "" {
 			result.Violations = append(result.Violations, Violation{
-				Level:   "WARN",
+				Level:   scope.level("forbid_empty_body"),
 				Rule:    "forbid_empty_body",
 				Message: "module has empty body",
 				Module:  id,
+				Scope:   scope.Origin["forbid_empty_body"],
 			})
 		}
 
 		for _, field := range scope.RequireFields {
 			if !hasField(m.Front, field) {
 				result.Violations = append(result.Violations, Violation{
-					Level:   "WARN",
+					Level:   scope.level("require_fields"),
 					Rule:    "require_fields",
 					Message: "missing required field '" + field + "'",
 					Module:  id,
+					Scope:   scope.Origin["require_fields"],
 				})
 			}
 		}
@@ -242,40 +428,52 @@ func Run(promptsDir string, cfg Config) (*Result, error) {
 			for _, t := range m.Front.Tags {
 				if t == ft {
 					result.Violations = append(result.Violations, Violation{
-						Level:   "WARN",
+						Level:   scope.level("forbid_tags"),
 						Rule:    "forbid_tags",
 						Message: "module has forbidden tag '" + ft + "'",
 						Module:  id,
+						Scope:   scope.Origin["forbid_tags"],
 					})
 				}
 			}
 		}
 
-		for _, cp := range cfg.ForbidContentPatterns {
-			if len(cp.Paths) > 0 && !matchPaths(m.Path, cp.Paths) {
+		for _, cp := range scope.ContentPatterns {
+			if len(cp.Paths) > 0 && !matchModulePaths(m.Path, relPath, cp.Paths) {
 				continue
 			}
 			re, err := regexp.Compile(cp.Match)
 			if err != nil {
 				result.Violations = append(result.Violations, Violation{
-					Level:   "WARN",
+					Level:   scope.level("forbid_content"),
 					Rule:    "forbid_content",
 					Message: fmt.Sprintf("invalid pattern %q: %v", cp.Match, err),
 					Module:  id,
+					Scope:   cp.Scope,
 				})
 				continue
 			}
 			if re.MatchString(m.Body) {
 				result.Violations = append(result.Violations, Violation{
-					Level:   "WARN",
+					Level:   scope.level("forbid_content"),
 					Rule:    "forbid_content",
 					Message: cp.Reason,
 					Module:  id,
+					Scope:   cp.Scope,
 				})
 			}
 		}
 	}
 
+	threshold, _ := failRank(cfg.FailOn)
+	result.Counts = map[string]int{"error": 0, "warn": 0, "info": 0}
+	for _, v := range result.Violations {
+		result.Counts[strings.ToLower(v.Level)]++
+		if threshold > 0 && levelRank[v.Level] >= threshold {
+			result.Failed = true
+		}
+	}
+
 	return result, nil
 }
 
@@ -284,19 +482,88 @@ type resolvedScope struct {
 	ForbidEmptyBody bool
 	RequireFields   []string
 	ForbidTags      []string
+	ContentPatterns []ContentPattern
+	Severity        map[string]string
+	// Origin maps a rule name to the label of the scope that set it;
+	// rules still at their global value are absent
+	Origin map[string]string
 }
 
-func resolveScope(_ string, cfg Config) resolvedScope {
+// resolveScope computes the effective per-module rules for a module.
+// Matching scopes apply in declaration order, so when several globs match
+// the same module, the scope declared last wins for each setting it sets.
+// Content patterns accumulate rather than override.
+func resolveScope(modPath, relPath string, cfg Config) resolvedScope {
 	scoped := resolvedScope{
 		MaxModuleWords:  cfg.MaxModuleWords,
 		ForbidEmptyBody: cfg.ForbidEmptyBody,
 		RequireFields:   cfg.RequireFields,
 		ForbidTags:      cfg.ForbidTags,
+		ContentPatterns: append([]ContentPattern{}, cfg.ForbidContentPatterns...),
+		Severity:        map[string]string{},
+		Origin:          map[string]string{},
+	}
+	for r, lvl := range cfg.Severity {
+		scoped.Severity[r] = lvl
+	}
+
+	for _, s := range cfg.Scopes {
+		if !matchModulePaths(modPath, relPath, s.Paths) {
+			continue
+		}
+		label := s.Label()
+		if s.MaxModuleWords != nil {
+			scoped.MaxModuleWords = *s.MaxModuleWords
+			scoped.Origin["max_module_words"] = label
+		}
+		if s.ForbidEmptyBody != nil {
+			scoped.ForbidEmptyBody = *s.ForbidEmptyBody
+			scoped.Origin["forbid_empty_body"] = label
+		}
+		if len(s.RequireFields) > 0 {
+			scoped.RequireFields = s.RequireFields
+			scoped.Origin["require_fields"] = label
+		}
+		if len(s.ForbidTags) > 0 {
+			scoped.ForbidTags = s.ForbidTags
+			scoped.Origin["forbid_tags"] = label
+		}
+		scoped.ContentPatterns = append(scoped.ContentPatterns, s.ContentPatterns...)
+		for r, lvl := range s.Severity {
+			scoped.Severity[r] = lvl
+		}
 	}
 
 	return scoped
 }
 
+func (s resolvedScope) level(rule string) string {
+	return levelFor(rule, s.Severity)
+}
+
+// relModulePath returns modPath relative to the prompts root it was loaded
+// from, so globs such as "guardrails/**" work regardless of where the
+// prompts directory lives
+func relModulePath(root, modPath string) string {
+	rel, err := filepath.Rel(root, modPath)
+	if err != nil {
+		return modPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// matchModulePaths matches patterns against the module path as loaded
+// and against the same path relative to the prompts directory. A vendored
+// module also matches by its path inside the package, so guardrails/*
+// covers vendor/<pkg>/guardrails/* as well as local guardrails.
+func matchModulePaths(modPath, relPath string, patterns []string) bool {
+	if matchPaths(modPath, patterns) || matchPaths(relPath, patterns) {
+		return true
+	}
+	_, inner, ok := vendoring.Split(relPath)
+	return ok && matchPaths(inner, patterns)
+}
+
 func matchPaths(modPath string, patterns []string) bool {
 	for _, pat := range patterns {
 		if matchGlob(modPath, pat) {
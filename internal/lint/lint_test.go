@@ -1,6 +1,10 @@
 package lint
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -137,6 +141,10 @@ func TestRunBasicStats(t *testing.T) {
 	if result.Stats["line_count"] == 0 {
 		t.Error("line_count should be > 0")
 	}
+
+	if result.Stats["token_count"] == 0 || len(result.Tokens) != 6 || result.Encoding == "" {
+		t.Errorf("token_count = %d, tokens = %v, encoding = %q", result.Stats["token_count"], result.Tokens, result.Encoding)
+	}
 }
 
 func TestRunMaxWords(t *testing.T) {
@@ -157,6 +165,24 @@ func TestRunMaxWords(t *testing.T) {
 	}
 }
 
+func TestRunMaxTokens(t *testing.T) {
+	result, err := Run("testdata", Config{MaxTokens: 5})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	found := false
+	for _, v := range result.Violations {
+		if v.Rule == "max_tokens" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Error("expected max_tokens violation")
+	}
+}
+
 func TestRunMaxLines(t *testing.T) {
 	result, err := Run("testdata", Config{MaxLines: 2})
 	if err != nil {
@@ -328,6 +354,7 @@ func TestRunMaxDepth(t *testing.T) {
 func TestMergeConfig(t *testing.T) {
 	file := model.LintConfig{
 		MaxWords:        2000,
+		MaxTokens:       8000,
 		MaxModuleWords:  500,
 		ForbidEmptyBody: true,
 		RequireTags:     []string{"risk:*"},
@@ -346,6 +373,9 @@ func TestMergeConfig(t *testing.T) {
 		if merged.MaxModuleWords != 500 {
 			t.Errorf("MaxModuleWords = %d, want 500", merged.MaxModuleWords)
 		}
+		if merged.MaxTokens != 8000 {
+			t.Errorf("MaxTokens = %d, want 8000", merged.MaxTokens)
+		}
 		if !merged.ForbidEmptyBody {
 			t.Error("ForbidEmptyBody should be true")
 		}
@@ -552,7 +582,7 @@ func TestResolveScope(t *testing.T) {
 		ForbidTags:      []string{"deprecated"},
 	}
 
-	scope := resolveScope("testdata/base.md", cfg)
+	scope := resolveScope("testdata/base.md", "base.md", cfg)
 	if scope.MaxModuleWords != 1000 {
 		t.Errorf("MaxModuleWords = %d, want 1000", scope.MaxModuleWords)
 	}
@@ -578,3 +608,228 @@ func TestMergeConfigEmptyFile(t *testing.T) {
 		t.Error("ForbidEmptyBody should be false")
 	}
 }
+
+func TestResolveScopePrecedence(t *testing.T) {
+	loose, strict := 500, 50
+	cfg := Config{
+		MaxModuleWords: 1000,
+		RequireFields:  []string{"id"},
+		Scopes: []Scope{
+			{Paths: []string{"traits_*"}, MaxModuleWords: &loose},
+			{Name: "deep", Paths: []string{"**/traits_deep*"}, MaxModuleWords: &strict, RequireFields: []string{"desc"}},
+		},
+	}
+
+	t.Run("last matching scope wins", func(t *testing.T) {
+		scope := resolveScope("testdata/traits_deep1.md", "traits_deep1.md", cfg)
+		if scope.MaxModuleWords != 50 {
+			t.Errorf("MaxModuleWords = %d, want 50", scope.MaxModuleWords)
+		}
+		if scope.Origin["max_module_words"] != "deep" {
+			t.Errorf("origin = %q, want deep", scope.Origin["max_module_words"])
+		}
+		if len(scope.RequireFields) != 1 || scope.RequireFields[0] != "desc" {
+			t.Errorf("RequireFields = %v, want [desc]", scope.RequireFields)
+		}
+	})
+
+	t.Run("unnamed scope labelled by paths", func(t *testing.T) {
+		scope := resolveScope("testdata/traits_empty.md", "traits_empty.md", cfg)
+		if scope.MaxModuleWords != 500 {
+			t.Errorf("MaxModuleWords = %d, want 500", scope.MaxModuleWords)
+		}
+		if scope.Origin["max_module_words"] != "traits_*" {
+			t.Errorf("origin = %q, want traits_*", scope.Origin["max_module_words"])
+		}
+		if _, ok := scope.Origin["require_fields"]; ok {
+			t.Error("require_fields should stay global")
+		}
+	})
+
+	t.Run("no match keeps global", func(t *testing.T) {
+		scope := resolveScope("testdata/base.md", "base.md", cfg)
+		if scope.MaxModuleWords != 1000 {
+			t.Errorf("MaxModuleWords = %d, want 1000", scope.MaxModuleWords)
+		}
+		if len(scope.Origin) != 0 {
+			t.Errorf("Origin = %v, want empty", scope.Origin)
+		}
+	})
+}
+
+func TestRunScopes(t *testing.T) {
+	one := 1
+	file := model.LintConfig{
+		Scopes: []model.LintScope{
+			{
+				Name:           "traits",
+				Paths:          []string{"traits_*"},
+				MaxModuleWords: &one,
+				ForbidTags:     []string{"risk:low"},
+				ContentPattern: []model.LintContentPattern{
+					{Match: "Deep", Reason: "no deep traits"},
+				},
+			},
+		},
+	}
+	cfg := MergeConfig(file, Config{}, CLISet{})
+
+	result, err := Run("testdata", cfg)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	rules := map[string]bool{}
+	for _, v := range result.Violations {
+		if v.Module == "base" {
+			t.Errorf("base is outside the scope, got %+v", v)
+		}
+		if v.Scope != "traits" {
+			t.Errorf("violation %+v should report scope traits", v)
+		}
+		rules[v.Rule] = true
+	}
+
+	for _, r := range []string{"max_module_words", "forbid_content"} {
+		if !rules[r] {
+			t.Errorf("expected scoped %s violation", r)
+		}
+	}
+}
+
+func TestRunScopesVendored(t *testing.T) {
+	dir := t.TempDir()
+	tdd := "---\nid: guardrails/tdd\n---\nWrite the failing test first.\n"
+	sum := sha256.Sum256([]byte(tdd))
+	for rel, content := range map[string]string{
+		"base.md":                         "---\nid: base\n---\nBase.\n",
+		"vendor/shared/guardrails/tdd.md": tdd,
+		"ppc.lock":                        fmt.Sprintf("packages:\n  - name: shared\n    source: ../shared\n    files:\n      guardrails/tdd.md: %x\n", sum),
+	} {
+		p := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := model.LintConfig{
+		Scopes: []model.LintScope{
+			{
+				Name:           "guardrails",
+				Paths:          []string{"guardrails/*"},
+				ContentPattern: []model.LintContentPattern{{Match: "failing", Reason: "no failing tests"}},
+			},
+		},
+	}
+	result, err := Run(dir, MergeConfig(file, Config{}, CLISet{}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Violations) != 1 {
+		t.Fatalf("Violations = %+v, want one for the vendored guardrail", result.Violations)
+	}
+	if v := result.Violations[0]; v.Module != "guardrails/tdd" || v.Scope != "guardrails" {
+		t.Errorf("violation = %+v, want guardrails/tdd in scope guardrails", v)
+	}
+}
+
+func TestRunSeverity(t *testing.T) {
+	t.Run("default level is warn and fails", func(t *testing.T) {
+		result, err := Run("testdata", Config{MaxModuleWords: 1})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Counts["warn"] == 0 || result.Counts["error"] != 0 {
+			t.Errorf("Counts = %v, want only warn", result.Counts)
+		}
+		if !result.Failed {
+			t.Error("warn violations should fail with default fail-on")
+		}
+	})
+
+	t.Run("info does not fail", func(t *testing.T) {
+		cfg := Config{MaxModuleWords: 1, Severity: map[string]string{"max_module_words": "info"}}
+		result, err := Run("testdata", cfg)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		for _, v := range result.Violations {
+			if v.Level != LevelInfo {
+				t.Errorf("Level = %q, want INFO", v.Level)
+			}
+		}
+		if result.Failed {
+			t.Error("info violations should not fail with default fail-on")
+		}
+	})
+
+	t.Run("fail-on error ignores warnings", func(t *testing.T) {
+		result, err := Run("testdata", Config{MaxModuleWords: 1, FailOn: "error"})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Failed {
+			t.Error("warn violations should not fail with fail-on error")
+		}
+	})
+
+	t.Run("scope severity overrides global", func(t *testing.T) {
+		cfg := Config{
+			MaxModuleWords: 1,
+			Severity:       map[string]string{"max_module_words": "info"},
+			Scopes: []Scope{
+				{Paths: []string{"base.md"}, Severity: map[string]string{"max_module_words": "error"}},
+			},
+		}
+		result, err := Run("testdata", cfg)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		for _, v := range result.Violations {
+			want := LevelInfo
+			if v.Module == "base" {
+				want = LevelError
+			}
+			if v.Level != want {
+				t.Errorf("%s: Level = %q, want %q", v.Module, v.Level, want)
+			}
+		}
+		if !result.Failed {
+			t.Error("error violation should fail")
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		bad := []Config{
+			{Severity: map[string]string{"max_words": "fatal"}},
+			{Severity: map[string]string{"no_such_rule": "warn"}},
+			{FailOn: "sometimes"},
+			{Scopes: []Scope{{Paths: []string{"**"}, Severity: map[string]string{"max_words": "loud"}}}},
+		}
+		for _, cfg := range bad {
+			if _, err := Run("testdata", cfg); err == nil {
+				t.Errorf("expected error for %+v", cfg)
+			}
+		}
+	})
+}
+
+func TestMergeConfigFailOn(t *testing.T) {
+	file := model.LintConfig{FailOn: "error", Severity: map[string]string{"max_words": "info"}}
+
+	merged := MergeConfig(file, Config{}, CLISet{})
+	if merged.FailOn != "error" {
+		t.Errorf("FailOn = %q, want error", merged.FailOn)
+	}
+	if merged.Severity["max_words"] != "info" {
+		t.Errorf("Severity = %v, want max_words: info", merged.Severity)
+	}
+
+	merged = MergeConfig(file, Config{FailOn: "none"}, CLISet{})
+	if merged.FailOn != "none" {
+		t.Errorf("FailOn = %q, want none (CLI override)", merged.FailOn)
+	}
+}
@@ -2,10 +2,13 @@ package loader
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	errtypes "github.com/bkuri/ppc/internal/error"
 	"github.com/bkuri/ppc/internal/model"
+	"github.com/bkuri/ppc/internal/semver"
+	"github.com/bkuri/ppc/internal/substitute"
 	"gopkg.in/yaml.v3"
 )
 
@@ -37,5 +40,83 @@ func ParseFrontmatter(raw []byte) (model.Frontmatter, string, bool, errtypes.Src
 		return model.Frontmatter{}, "", false,
 			errtypes.New("", "", fmt.Sprintf("invalid YAML frontmatter: %v", err))
 	}
+	if err := splitConstraints(&fm); err != nil {
+		return model.Frontmatter{}, "", false, errtypes.New("", fm.ID, err.Error())
+	}
+	if err := checkVarSpecs(fm.Vars); err != nil {
+		return model.Frontmatter{}, "", false, errtypes.New("", fm.ID, err.Error())
+	}
 	return fm, body, true, errtypes.SrcError{}
 }
+
+// splitConstraints validates version and moves the version constraint of
+// each requires entry into Constraints, leaving the bare module ID. The
+// constraint starts at the first whitespace or operator character, so
+// "guardrails/tdd >=2.0" and "guardrails/tdd>=2.0" are equivalent.
+func splitConstraints(fm *model.Frontmatter) error {
+	if fm.Version != "" {
+		if _, err := semver.Parse(fm.Version); err != nil {
+			return err
+		}
+	}
+	for i, r := range fm.Requires {
+		r = strings.TrimSpace(r)
+		sp := strings.IndexAny(r, " \t<>=!^~")
+		if sp == -1 {
+			fm.Requires[i] = r
+			continue
+		}
+		id, constraint := r[:sp], strings.TrimSpace(r[sp:])
+		if id == "" {
+			return fmt.Errorf("requires %q: missing module ID before the version constraint", r)
+		}
+		if _, err := semver.ParseConstraint(constraint); err != nil {
+			return fmt.Errorf("requires %s: %v", id, err)
+		}
+		if fm.Constraints == nil {
+			fm.Constraints = map[string]string{}
+		}
+		fm.Requires[i] = id
+		fm.Constraints[id] = constraint
+	}
+	return nil
+}
+
+// checkVarSpecs validates the type and default of each vars declaration
+func checkVarSpecs(specs map[string]model.VarSpec) error {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := specs[name]
+		if !substitute.ValidType(spec.Type) {
+			return fmt.Errorf("vars %s: unknown type %q (expected %s)", name, spec.Type, strings.Join(substitute.Types, ", "))
+		}
+		if spec.Default == nil {
+			continue
+		}
+		if _, err := substitute.Coerce(spec.Type, spec.Default); err != nil {
+			return fmt.Errorf("vars %s: invalid default: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// BodyLine returns the 1-based line of raw on which the body returned by
+// ParseFrontmatter starts
+func BodyLine(raw []byte) int {
+	s := string(raw)
+	start := 0
+	if strings.HasPrefix(s, "---\n") || strings.HasPrefix(s, "---\r\n") {
+		if idx := strings.Index(s[4:], "\n---\n"); idx != -1 {
+			start = 4 + idx + len("\n---\n")
+		} else if idx := strings.Index(s[4:], "\r\n---\r\n"); idx != -1 {
+			start = 4 + idx + len("\r\n---\r\n")
+		}
+	}
+	rest := s[start:]
+	start += len(rest) - len(strings.TrimLeft(rest, "\r\n"))
+	return strings.Count(s[:start], "\n") + 1
+}
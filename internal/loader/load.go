@@ -2,7 +2,11 @@
 package loader
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,6 +14,7 @@ import (
 
 	errtypes "github.com/bkuri/ppc/internal/error"
 	"github.com/bkuri/ppc/internal/model"
+	"github.com/bkuri/ppc/internal/vendoring"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,43 +37,136 @@ func ListMarkdownFiles(root string) []string {
 	return out
 }
 
-// LoadModules loads all module files from promptsDir
+// Roots splits promptsDir into its prompts roots. Several roots are
+// separated by os.PathListSeparator and listed in precedence order: a
+// module in a later root may replace one from an earlier root only when it
+// declares overrides: <id>.
+func Roots(promptsDir string) []string {
+	roots := filepath.SplitList(promptsDir)
+	if len(roots) == 0 {
+		return []string{promptsDir}
+	}
+	return roots
+}
+
+// JoinRoots joins prompts roots into a single promptsDir value
+func JoinRoots(roots []string) string {
+	return strings.Join(roots, string(os.PathListSeparator))
+}
+
+// LoadModules loads all module files from every root in promptsDir.
+// Layers follow the order declared in the first root's rules.yml, if present.
 func LoadModules(promptsDir string) (map[string]*model.Module, error) {
-	files := ListMarkdownFiles(promptsDir)
+	order, declared, err := LayerOrder(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := Roots(promptsDir)
 	modByID := map[string]*model.Module{}
+	for i, root := range roots {
+		if i > 0 {
+			p := filepath.Join(root, "rules.yml")
+			if _, err := os.Stat(p); err == nil {
+				return nil, errtypes.New(p, "", fmt.Sprintf("rules.yml is only read from the first prompts root (%s)", roots[0]))
+			}
+		}
+		if err := loadRoot(root, order, declared, i > 0, modByID); err != nil {
+			return nil, err
+		}
+	}
+	return modByID, nil
+}
 
-	for _, p := range files {
+// loadRoot adds the modules under root to modByID. A module outside the
+// layers is an error when they are declared, and is placed by
+// model.LayerIndexFromPath otherwise. Overrides are only accepted when
+// layered is set, i.e. root is not the first prompts root.
+func loadRoot(root string, order []string, declared, layered bool, modByID map[string]*model.Module) error {
+	if err := vendoring.Verify(root); err != nil {
+		return errtypes.New(filepath.Join(root, vendoring.LockFile), "", err.Error())
+	}
+	// seen maps each ID defined in this root to its file
+	seen := map[string]string{}
+	for _, p := range ListMarkdownFiles(root) {
 		raw, err := os.ReadFile(p)
 		if err != nil {
-			return nil, errtypes.New(p, "", fmt.Sprintf("failed to read: %v", err))
+			return errtypes.New(p, "", fmt.Sprintf("failed to read: %v", err))
 		}
 		fm, body, has, parseErr := ParseFrontmatter(raw)
 		if parseErr.Msg != "" {
-			return nil, parseErr
+			return parseErr
 		}
 		if !has {
-			return nil, errtypes.New(p, "", "missing frontmatter (v0.1 requires YAML frontmatter with id)")
+			return errtypes.New(p, "", "missing frontmatter (v0.1 requires YAML frontmatter with id)")
 		}
 		if strings.TrimSpace(fm.ID) == "" {
-			return nil, errtypes.New(p, "", "frontmatter missing required field: id")
+			return errtypes.New(p, "", "frontmatter missing required field: id")
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			rel = p
+		}
+		// Vendored modules keep their layer directories inside the package
+		_, inner, vendored := vendoring.Split(rel)
+		if vendored {
+			rel = inner
+		}
+		layer, ok := model.LayerIndexIn(order, rel)
+		if !ok && declared {
+			return errtypes.New(p, fm.ID, fmt.Sprintf("directory is not a declared layer (layers: %s)", strings.Join(order, ", ")))
+		}
+		if !ok {
+			layer = model.LayerIndexFromPath(rel)
 		}
 		m := &model.Module{
 			Path:  p,
-			Layer: model.LayerIndexFromPath(filepath.ToSlash(p)),
+			Root:  root,
+			Hash:  sha256Hex(raw),
+			Layer: layer,
 			Front: fm,
 			Body:  body,
+			Line:  BodyLine(raw),
 		}
-		if _, exists := modByID[fm.ID]; exists {
-			return nil, errtypes.New(p, fm.ID, fmt.Sprintf("duplicate module id %q", fm.ID))
+		if other, dup := seen[fm.ID]; dup {
+			msg := fmt.Sprintf("duplicate module id %q", fm.ID)
+			if vendored || isVendored(root, other) {
+				// Vendored modules keep their IDs; they are not namespaced
+				// by package, so they share one ID space with the root
+				msg = fmt.Sprintf("duplicate module id %q (also in %s; vendored modules keep their IDs, so rename or drop one of them)", fm.ID, other)
+			}
+			return errtypes.New(p, fm.ID, msg)
+		}
+		seen[fm.ID] = p
+
+		prev, exists := modByID[fm.ID]
+		switch {
+		case fm.Overrides != "" && fm.Overrides != fm.ID:
+			return errtypes.New(p, fm.ID, fmt.Sprintf("overrides %q must match the module id", fm.Overrides))
+		case fm.Overrides != "" && (!layered || !exists):
+			return errtypes.New(p, fm.ID, fmt.Sprintf("overrides %q but no earlier prompts root defines it", fm.Overrides))
+		case exists && fm.Overrides == "":
+			return errtypes.New(p, fm.ID, fmt.Sprintf("duplicate module id %q (also in %s; declare overrides: %s to replace it)", fm.ID, prev.Path, fm.ID))
 		}
 		modByID[fm.ID] = m
 	}
-	return modByID, nil
+	return nil
+}
+
+// isVendored reports whether the module file p lies under root's vendor
+// directory
+func isVendored(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	_, _, ok := vendoring.Split(rel)
+	return ok
 }
 
-// LoadRules loads the rules.yml file from promptsDir
+// LoadRules loads the rules.yml file from the first root in promptsDir
 func LoadRules(promptsDir string) (*model.Rules, error) {
-	p := filepath.Join(promptsDir, "rules.yml")
+	p := filepath.Join(Roots(promptsDir)[0], "rules.yml")
 	b, err := os.ReadFile(p)
 	if err != nil {
 		return nil, errtypes.New(p, "", fmt.Sprintf("missing rules file: %v", err))
@@ -77,5 +175,28 @@ func LoadRules(promptsDir string) (*model.Rules, error) {
 	if err := yaml.Unmarshal(b, &r); err != nil {
 		return nil, errtypes.New(p, "", fmt.Sprintf("invalid rules.yml: %v", err))
 	}
+	if err := model.ValidateLayers(r.Layers); err != nil {
+		return nil, errtypes.New(p, "", fmt.Sprintf("invalid rules.yml: layers: %v", err))
+	}
+	r.Hash = sha256Hex(b)
 	return &r, nil
 }
+
+// LayerOrder returns the layer order for promptsDir: the layers declared in
+// rules.yml, or model.LayerOrder when rules.yml is absent or declares none.
+// declared reports whether rules.yml declares layers.
+func LayerOrder(promptsDir string) (order []string, declared bool, err error) {
+	if _, err := os.Stat(filepath.Join(Roots(promptsDir)[0], "rules.yml")); errors.Is(err, fs.ErrNotExist) {
+		return model.LayerOrder, false, nil
+	}
+	r, err := LoadRules(promptsDir)
+	if err != nil {
+		return nil, false, err
+	}
+	return r.LayerOrder(), len(r.Layers) > 0, nil
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
@@ -99,6 +99,64 @@ func TestParseFrontmatter(t *testing.T) {
 		}
 	})
 
+	t.Run("requires with version constraint", func(t *testing.T) {
+		raw := []byte("---\nid: modes/build\nversion: 1.2\nrequires:\n  - base\n  - guardrails/tdd >=2.0, <3\n---\nBody.\n")
+		fm, _, _, err := ParseFrontmatter(raw)
+		if err.Msg != "" {
+			t.Fatalf("unexpected error: %s", err.Msg)
+		}
+		if len(fm.Requires) != 2 || fm.Requires[1] != "guardrails/tdd" {
+			t.Errorf("Requires = %v, want bare IDs", fm.Requires)
+		}
+		if fm.Constraints["guardrails/tdd"] != ">=2.0, <3" || len(fm.Constraints) != 1 {
+			t.Errorf("Constraints = %v, want guardrails/tdd: >=2.0, <3", fm.Constraints)
+		}
+		if fm.Version != "1.2" {
+			t.Errorf("Version = %q, want 1.2", fm.Version)
+		}
+	})
+
+	t.Run("requires constraint with or without a space", func(t *testing.T) {
+		for _, entry := range []string{"guardrails/tdd >=1.2", "guardrails/tdd>=1.2", "guardrails/tdd\t>=1.2"} {
+			raw := []byte("---\nid: modes/build\nrequires:\n  - \"" + entry + "\"\n---\nBody.\n")
+			fm, _, _, err := ParseFrontmatter(raw)
+			if err.Msg != "" {
+				t.Fatalf("%q: unexpected error: %s", entry, err.Msg)
+			}
+			if len(fm.Requires) != 1 || fm.Requires[0] != "guardrails/tdd" || fm.Constraints["guardrails/tdd"] != ">=1.2" {
+				t.Errorf("%q: Requires = %v, Constraints = %v, want guardrails/tdd >=1.2", entry, fm.Requires, fm.Constraints)
+			}
+		}
+		_, _, _, err := ParseFrontmatter([]byte("---\nid: a\nrequires:\n  - \">=1.2\"\n---\nBody.\n"))
+		if !strings.Contains(err.Msg, "missing module ID") {
+			t.Errorf("error = %q, want missing module ID", err.Msg)
+		}
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		for _, raw := range []string{
+			"---\nid: a\nversion: two\n---\nBody.\n",
+			"---\nid: a\nrequires:\n  - b >=x\n---\nBody.\n",
+		} {
+			_, _, _, err := ParseFrontmatter([]byte(raw))
+			if !strings.Contains(err.Msg, "invalid version") {
+				t.Errorf("error = %q, want invalid version", err.Msg)
+			}
+		}
+	})
+
+	t.Run("invalid vars declarations", func(t *testing.T) {
+		for raw, want := range map[string]string{
+			"---\nid: a\nvars:\n  n:\n    type: float\n---\nBody.\n":               `vars n: unknown type "float"`,
+			"---\nid: a\nvars:\n  n:\n    type: int\n    default: x\n---\nBody.\n": `vars n: invalid default: expected int`,
+		} {
+			_, _, _, err := ParseFrontmatter([]byte(raw))
+			if !strings.Contains(err.Msg, want) {
+				t.Errorf("error = %q, want %q", err.Msg, want)
+			}
+		}
+	})
+
 	t.Run("frontmatter with priority", func(t *testing.T) {
 		raw := []byte("---\nid: policies/rule\npriority: 10\n---\nRule body.\n")
 		fm, _, has, err := ParseFrontmatter(raw)
@@ -114,6 +172,26 @@ func TestParseFrontmatter(t *testing.T) {
 	})
 }
 
+func TestBodyLine(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{"after frontmatter", "---\nid: a\n---\nBody\n", 4},
+		{"skips blank lines", "---\nid: a\ntags: []\n---\n\n\nBody\n", 7},
+		{"crlf", "---\r\nid: a\r\n---\r\n\r\nBody\r\n", 5},
+		{"no frontmatter", "Body\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BodyLine([]byte(tt.raw)); got != tt.want {
+				t.Errorf("BodyLine(%q) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestLoadModules(t *testing.T) {
 	t.Run("valid directory", func(t *testing.T) {
 		modByID, err := LoadModules("testdata/valid")
@@ -223,3 +301,151 @@ func TestLoadRules(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadModulesLayers(t *testing.T) {
+	t.Run("declared layer order", func(t *testing.T) {
+		modByID, err := LoadModules("testdata/custom_layers")
+		if err != nil {
+			t.Fatalf("LoadModules failed: %s", err)
+		}
+		want := map[string]int{
+			"base":              0,
+			"personas/reviewer": 1,
+			"modes/explore":     2,
+			"examples/sample":   3,
+		}
+		for id, layer := range want {
+			if got := modByID[id].Layer; got != layer {
+				t.Errorf("%s layer = %d, want %d", id, got, layer)
+			}
+		}
+	})
+
+	t.Run("undeclared directory fails", func(t *testing.T) {
+		_, err := LoadModules("testdata/undeclared_layer")
+		if err == nil {
+			t.Fatal("expected error for module outside declared layers")
+		}
+		if !strings.Contains(err.Error(), "not a declared layer") {
+			t.Errorf("error = %q, want to contain 'not a declared layer'", err.Error())
+		}
+	})
+
+	t.Run("undeclared directory without declared layers", func(t *testing.T) {
+		modByID, err := LoadModules("testdata/implicit_layers")
+		if err != nil {
+			t.Fatalf("LoadModules failed: %s", err)
+		}
+		want := map[string]int{
+			"base":              0,
+			"extras/foo":        0,
+			"extras/modes/bar":  1,
+			"guardrails/safety": 5,
+		}
+		for id, layer := range want {
+			if got := modByID[id].Layer; got != layer {
+				t.Errorf("%s layer = %d, want %d", id, got, layer)
+			}
+		}
+	})
+
+	t.Run("default order without rules file", func(t *testing.T) {
+		order, declared, err := LayerOrder("testdata/skip_nonmd")
+		if err != nil {
+			t.Fatalf("LayerOrder failed: %s", err)
+		}
+		if len(order) != 6 || order[0] != "base" || declared {
+			t.Errorf("order = %v, declared = %v, want default layer order", order, declared)
+		}
+	})
+}
+
+func TestLoadModulesRoots(t *testing.T) {
+	roots := func(dirs ...string) string {
+		for i, d := range dirs {
+			dirs[i] = "testdata/layered/" + d
+		}
+		return JoinRoots(dirs)
+	}
+
+	t.Run("explicit override replaces module", func(t *testing.T) {
+		modByID, err := LoadModules(roots("shared", "team"))
+		if err != nil {
+			t.Fatalf("LoadModules failed: %s", err)
+		}
+		if len(modByID) != 4 {
+			t.Errorf("got %d modules, want 4", len(modByID))
+		}
+		tdd := modByID["guardrails/tdd"]
+		if tdd.Body != "Team TDD." {
+			t.Errorf("guardrails/tdd body = %q, want team override", tdd.Body)
+		}
+		if tdd.Root != "testdata/layered/team" {
+			t.Errorf("guardrails/tdd root = %q, want testdata/layered/team", tdd.Root)
+		}
+		if got := modByID["base"].Root; got != "testdata/layered/shared" {
+			t.Errorf("base root = %q, want testdata/layered/shared", got)
+		}
+		if got := modByID["traits/local"].Layer; got != 2 {
+			t.Errorf("traits/local layer = %d, want 2", got)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		dirs    []string
+		wantErr string
+	}{
+		{"silent shadowing", []string{"shared", "shadow"}, "declare overrides: guardrails/tdd"},
+		{"override without target", []string{"shared", "orphan"}, "no earlier prompts root defines it"},
+		{"override in first root", []string{"team"}, "no earlier prompts root defines it"},
+		{"rules in later root", []string{"shared", "rules"}, "only read from the first prompts root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadModules(roots(tt.dirs...))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadModulesVendored(t *testing.T) {
+	t.Run("locked vendor tree", func(t *testing.T) {
+		modByID, err := LoadModules("testdata/vendored")
+		if err != nil {
+			t.Fatalf("LoadModules failed: %s", err)
+		}
+		tdd, ok := modByID["guardrails/tdd"]
+		if !ok {
+			t.Fatal("missing vendored module 'guardrails/tdd'")
+		}
+		if tdd.Layer != 5 {
+			t.Errorf("guardrails/tdd layer = %d, want 5", tdd.Layer)
+		}
+	})
+
+	t.Run("vendored ID colliding with a local module", func(t *testing.T) {
+		_, err := LoadModules("testdata/vendored_duplicate")
+		if err == nil {
+			t.Fatal("expected duplicate ID error")
+		}
+		if !strings.Contains(err.Error(), `duplicate module id "guardrails/tdd"`) || !strings.Contains(err.Error(), "vendored modules keep their IDs") {
+			t.Errorf("error = %q, want vendored duplicate ID error", err.Error())
+		}
+	})
+
+	t.Run("hash mismatch fails", func(t *testing.T) {
+		_, err := LoadModules("testdata/vendored_tampered")
+		if err == nil {
+			t.Fatal("expected error for vendored file not matching ppc.lock")
+		}
+		if !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("error = %q, want to contain 'does not match'", err.Error())
+		}
+	})
+}
@@ -2,6 +2,7 @@
 package model
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 )
@@ -15,12 +16,14 @@ type LintContentPattern struct {
 
 // LintScope defines path-scoped lint overrides
 type LintScope struct {
+	Name           string               `yaml:"name,omitempty"`
 	Paths          []string             `yaml:"paths"`
 	MaxModuleWords *int                 `yaml:"max_module_words,omitempty"`
 	ForbidEmpty    *bool                `yaml:"forbid_empty_body,omitempty"`
 	RequireFields  []string             `yaml:"require_fields,omitempty"`
 	ForbidTags     []string             `yaml:"forbid_tags,omitempty"`
 	ContentPattern []LintContentPattern `yaml:"forbid_content_patterns,omitempty"`
+	Severity       map[string]string    `yaml:"severity,omitempty"`
 }
 
 // LintConfig defines persistent lint configuration
@@ -30,18 +33,39 @@ type LintConfig struct {
 	MaxModules            int                  `yaml:"max_modules"`
 	MaxModuleWords        int                  `yaml:"max_module_words"`
 	MaxDepth              int                  `yaml:"max_depth"`
+	MaxTokens             int                  `yaml:"max_tokens"`
 	RequireTags           []string             `yaml:"require_tags"`
 	ForbidTags            []string             `yaml:"forbid_tags"`
 	RequireFields         []string             `yaml:"require_fields"`
 	ForbidEmptyBody       bool                 `yaml:"forbid_empty_body"`
 	ForbidContentPatterns []LintContentPattern `yaml:"forbid_content_patterns"`
 	Scopes                []LintScope          `yaml:"scopes"`
+	Severity              map[string]string    `yaml:"severity"`
+	FailOn                string               `yaml:"fail_on"`
+}
+
+// SubstitutionConfig controls variable substitution in module bodies
+type SubstitutionConfig struct {
+	// SkipCodeBlocks leaves fenced code blocks unsubstituted
+	SkipCodeBlocks bool `yaml:"skip_code_blocks"`
 }
 
 // Rules defines validation rules for modules
 type Rules struct {
-	ExclusiveGroups []string   `yaml:"exclusive_groups"`
-	Lint            LintConfig `yaml:"lint"`
+	ExclusiveGroups []string           `yaml:"exclusive_groups"`
+	Layers          []string           `yaml:"layers"`
+	Lint            LintConfig         `yaml:"lint"`
+	Substitution    SubstitutionConfig `yaml:"substitution"`
+	Hash            string             `yaml:"-"`
+}
+
+// LayerOrder returns the layer order declared in rules.yml, or the
+// default LayerOrder when none is declared
+func (r *Rules) LayerOrder() []string {
+	if r == nil || len(r.Layers) == 0 {
+		return LayerOrder
+	}
+	return r.Layers
 }
 
 // Frontmatter represents the YAML frontmatter of a module
@@ -50,15 +74,67 @@ type Frontmatter struct {
 	Desc     string   `yaml:"desc"`
 	Priority int      `yaml:"priority"`
 	Tags     []string `yaml:"tags"`
-	Requires []string `yaml:"requires"`
+	// Version is the module's MAJOR[.MINOR[.PATCH]] version, if any
+	Version string `yaml:"version"`
+	// Requires lists module IDs. An entry may carry a version
+	// constraint after the ID ("guardrails/tdd >=2.0"); the loader
+	// moves it to Constraints, keyed by ID.
+	Requires    []string          `yaml:"requires"`
+	Constraints map[string]string `yaml:"-"`
+	// Suggests lists modules included only when present and selected
+	// by another rule; a missing target is not an error
+	Suggests []string `yaml:"suggests"`
+	// RequiresAny is satisfied by any one of the listed IDs or ID
+	// patterns (e.g. contracts/*)
+	RequiresAny []string `yaml:"requires_any"`
+	// Conflicts lists modules that must never be compiled together with
+	// this one
+	Conflicts []string `yaml:"conflicts"`
+	// AppliesTo restricts the modes, contracts and profiles the module
+	// may be compiled with
+	AppliesTo AppliesTo `yaml:"applies_to"`
+	// Vars declares the variables the body references, by name or
+	// dotted path
+	Vars map[string]VarSpec `yaml:"vars"`
+	// Overrides names the module this one replaces from an earlier
+	// prompts root; it must equal ID
+	Overrides string `yaml:"overrides"`
+}
+
+// VarSpec declares a variable: its type (string, int, bool, list or map;
+// string when empty), whether it must be supplied, and a default used
+// when it is not
+type VarSpec struct {
+	Type        string `yaml:"type"`
+	Required    bool   `yaml:"required"`
+	Default     any    `yaml:"default"`
+	Description string `yaml:"description"`
+}
+
+// AppliesTo lists the modes, contracts and profiles a module is limited
+// to, by name (ship, code) or module ID (modes/ship). An empty list does
+// not restrict.
+type AppliesTo struct {
+	Modes     []string `yaml:"modes"`
+	Contracts []string `yaml:"contracts"`
+	Profiles  []string `yaml:"profiles"`
+}
+
+// IsZero reports whether a places no restriction
+func (a AppliesTo) IsZero() bool {
+	return len(a.Modes) == 0 && len(a.Contracts) == 0 && len(a.Profiles) == 0
 }
 
 // Module represents a compiled module with metadata
 type Module struct {
-	Path     string
-	Layer    int
-	Front    Frontmatter
-	Body     string
+	Path  string
+	Root  string
+	Hash  string
+	Layer int
+	Front Frontmatter
+	Body  string
+	// Line is the 1-based line of the file on which Body starts
+	Line     int
 	FromReq  bool
 	Selected bool
 }
@@ -67,14 +143,60 @@ type Module struct {
 var LayerOrder = []string{"base", "modes", "traits", "policies", "contracts", "guardrails"}
 
 func LayerName(index int) string {
-	if index < 0 || index >= len(LayerOrder) {
+	return LayerNameIn(LayerOrder, index)
+}
+
+// LayerNameIn returns the name of a layer index within order
+func LayerNameIn(order []string, index int) string {
+	if index < 0 || index >= len(order) {
 		return "unknown"
 	}
-	return LayerOrder[index]
+	return order[index]
 }
 
-// LayerIndexFromPath returns the layer index for a module path
+// ValidateLayers checks a declared layer order for empty, nested,
+// reserved or duplicate names
+func ValidateLayers(order []string) error {
+	seen := map[string]bool{}
+	for _, l := range order {
+		if l == "" || strings.ContainsAny(l, `/\`) {
+			return fmt.Errorf("invalid layer name %q (expected a single directory name)", l)
+		}
+		if l == "vendor" {
+			return fmt.Errorf("layer name %q is reserved for vendored packages", l)
+		}
+		if seen[l] {
+			return fmt.Errorf("duplicate layer %q", l)
+		}
+		seen[l] = true
+	}
+	return nil
+}
+
+// LayerIndexIn returns the layer of a module from its path relative to the
+// prompts directory. Files at the root belong to the first layer; files in
+// a subdirectory belong to the layer named by the top-level directory.
+// ok is false when that directory is not in order.
+func LayerIndexIn(order []string, relPath string) (index int, ok bool) {
+	dir, _, nested := strings.Cut(filepath.ToSlash(relPath), "/")
+	if !nested {
+		return 0, true
+	}
+	for i, l := range order {
+		if l == dir {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// LayerIndexFromPath returns the layer index for a module path in the
+// default layer order. A path outside the layers falls back to the first
+// layer named by any of its segments, or the first layer.
 func LayerIndexFromPath(p string) int {
+	if i, ok := LayerIndexIn(LayerOrder, p); ok {
+		return i
+	}
 	parts := strings.Split(filepath.ToSlash(p), "/")
 	for i, s := range LayerOrder {
 		for _, part := range parts {
@@ -54,3 +54,48 @@ func TestLayerIndexFromPath(t *testing.T) {
 		})
 	}
 }
+
+func TestLayerIndexIn(t *testing.T) {
+	order := []string{"base", "personas", "modes"}
+	tests := []struct {
+		path  string
+		index int
+		ok    bool
+	}{
+		{"base.md", 0, true},
+		{"personas/reviewer.md", 1, true},
+		{"modes/deep/explore.md", 2, true},
+		{"traits/terse.md", -1, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			index, ok := LayerIndexIn(order, tc.path)
+			if index != tc.index || ok != tc.ok {
+				t.Errorf("LayerIndexIn(%q) = %d, %v, want %d, %v", tc.path, index, ok, tc.index, tc.ok)
+			}
+		})
+	}
+}
+
+func TestValidateLayers(t *testing.T) {
+	if err := ValidateLayers([]string{"base", "personas"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range [][]string{{"base", "base"}, {""}, {"a/b"}} {
+		if err := ValidateLayers(bad); err == nil {
+			t.Errorf("ValidateLayers(%v) should fail", bad)
+		}
+	}
+}
+
+func TestRulesLayerOrder(t *testing.T) {
+	var nilRules *Rules
+	if got := nilRules.LayerOrder(); len(got) != len(LayerOrder) {
+		t.Errorf("nil rules order = %v, want default", got)
+	}
+	r := &Rules{Layers: []string{"base", "personas"}}
+	if got := r.LayerOrder(); len(got) != 2 || got[1] != "personas" {
+		t.Errorf("declared order = %v, want [base personas]", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,6 +12,7 @@ import (
 // LoadProfile loads a built-in profile by name
 // Example: LoadProfile("ship") → profiles/ship.yml
 // Built-in profiles: explore, build, ship
+// Use Resolve to search the full profile search path.
 func LoadProfile(name string) (*Profile, error) {
 	path := filepath.Join("profiles", name+".yml")
 	return LoadProfileFromFile(path)
@@ -19,7 +21,88 @@ func LoadProfile(name string) (*Profile, error) {
 // LoadProfileFromFile loads a profile from an arbitrary file path
 // Supports both absolute and relative paths
 // Example: LoadProfileFromFile("./custom.yml")
+//
+// The extends chain is resolved and merged before validation, so only the
+// effective profile needs mode and contract. Relative vars_file and prompts
+// paths are resolved against the directory of the profile declaring them.
 func LoadProfileFromFile(path string) (*Profile, error) {
+	p, err := loadChain(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func loadChain(path string, chain []string) (*Profile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", path, err)
+	}
+	for _, seen := range chain {
+		if seen == abs {
+			return nil, fmt.Errorf("profile %q: circular extends: %s", path, strings.Join(append(chain, abs), " -> "))
+		}
+	}
+
+	p, err := readProfile(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.Extends == "" {
+		return p, nil
+	}
+
+	parent, err := loadChain(extendsPath(path, p.Extends), append(chain, abs))
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: extends %q: %w", path, p.Extends, err)
+	}
+	return Merge(parent, p), nil
+}
+
+// ChainFiles returns path followed by the files of the profiles it
+// extends, nearest first
+func ChainFiles(path string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	for {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", path, err)
+		}
+		if seen[abs] {
+			return files, nil
+		}
+		seen[abs] = true
+		files = append(files, path)
+
+		p, err := readProfile(path)
+		if err != nil {
+			return files, err
+		}
+		if p.Extends == "" {
+			return files, nil
+		}
+		path = extendsPath(path, p.Extends)
+	}
+}
+
+// ChainNames returns the profile names of files, as returned by
+// ChainFiles: each file name without its extension
+func ChainNames(files []string) []string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		base := filepath.Base(f)
+		names = append(names, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	return names
+}
+
+func readProfile(path string) (*Profile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("profile %q: %w", path, err)
@@ -30,9 +113,28 @@ func LoadProfileFromFile(path string) (*Profile, error) {
 		return nil, fmt.Errorf("profile %q: invalid YAML: %w", path, err)
 	}
 
-	if err := p.Validate(); err != nil {
-		return nil, err
-	}
+	dir := filepath.Dir(path)
+	p.VarsFile = resolveRelative(dir, p.VarsFile)
+	p.PromptsDir = resolveRelative(dir, p.PromptsDir)
 
 	return &p, nil
 }
+
+// extendsPath resolves an extends value relative to the extending profile:
+// a bare name maps to <dir>/<name>.yml, anything with an extension is a path
+func extendsPath(from, extends string) string {
+	if filepath.IsAbs(extends) {
+		return extends
+	}
+	if filepath.Ext(extends) == "" {
+		extends += ".yml"
+	}
+	return filepath.Join(filepath.Dir(from), extends)
+}
+
+func resolveRelative(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
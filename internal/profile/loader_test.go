@@ -0,0 +1,124 @@
+package profile
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfileFromFileExtends(t *testing.T) {
+	p, err := LoadProfileFromFile("testdata/backend.yml")
+	if err != nil {
+		t.Fatalf("LoadProfileFromFile failed: %v", err)
+	}
+
+	if p.Mode != "build" || p.Contract != "code" {
+		t.Errorf("Mode/Contract = %s/%s, want build/code (inherited)", p.Mode, p.Contract)
+	}
+	if p.Revisions == nil || *p.Revisions != 1 {
+		t.Errorf("Revisions = %v, want 1 (inherited)", p.Revisions)
+	}
+
+	wantTraits := []string{"traits/conservative", "traits/terse"}
+	if !reflect.DeepEqual(p.Traits, wantTraits) {
+		t.Errorf("Traits = %v, want %v", p.Traits, wantTraits)
+	}
+	wantGuardrails := []string{"tdd", "snake_case"}
+	if !reflect.DeepEqual(p.Guardrails, wantGuardrails) {
+		t.Errorf("Guardrails = %v, want %v", p.Guardrails, wantGuardrails)
+	}
+	if !reflect.DeepEqual(p.Policies, []string{"self_score"}) {
+		t.Errorf("Policies = %v, want [self_score]", p.Policies)
+	}
+
+	team, _ := p.Vars["team"].(map[string]any)
+	if team["name"] != "platform" || team["size"] != 6 {
+		t.Errorf("vars.team = %v, want name=platform size=6", team)
+	}
+
+	if p.VarsFile != filepath.Join("testdata", "vars.yml") {
+		t.Errorf("VarsFile = %q, want relative to declaring profile", p.VarsFile)
+	}
+	if p.Hash == nil || !*p.Hash || p.Out != "AGENTS.md" {
+		t.Errorf("Hash/Out = %v/%q, want true/AGENTS.md", p.Hash, p.Out)
+	}
+}
+
+func TestLoadProfileFromFileNestedPath(t *testing.T) {
+	p, err := LoadProfileFromFile("testdata/nested/service.yml")
+	if err != nil {
+		t.Fatalf("LoadProfileFromFile failed: %v", err)
+	}
+
+	if p.Mode != "ship" {
+		t.Errorf("Mode = %q, want ship", p.Mode)
+	}
+	if p.PromptsDir != "prompts" {
+		t.Errorf("PromptsDir = %q, want resolved against testdata/nested", p.PromptsDir)
+	}
+	if p.VarsFile != filepath.Join("testdata", "vars.yml") {
+		t.Errorf("VarsFile = %q, want inherited path relative to team.yml", p.VarsFile)
+	}
+}
+
+func TestLoadProfileFromFileErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"testdata/loop_a.yml", "circular extends"},
+		{"testdata/partial.yml", "mode is required"},
+		{"testdata/bad_guardrail.yml", "must be a name"},
+		{"testdata/missing.yml", "no such file"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			_, err := LoadProfileFromFile(tc.file)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMergeKeepsParentUntouched(t *testing.T) {
+	parent := &Profile{Mode: "build", Traits: []string{"traits/terse"}, Vars: map[string]any{"a": 1}}
+	child := &Profile{Traits: []string{"traits/verbose"}, Vars: map[string]any{"b": 2}}
+
+	merged := Merge(parent, child)
+
+	if len(parent.Traits) != 1 || len(parent.Vars) != 1 {
+		t.Errorf("parent mutated: %+v", parent)
+	}
+	if merged.Mode != "build" || len(merged.Traits) != 2 || len(merged.Vars) != 2 {
+		t.Errorf("merged = %+v", merged)
+	}
+}
+
+func TestChainFiles(t *testing.T) {
+	files, err := ChainFiles("testdata/backend.yml")
+	if err != nil {
+		t.Fatalf("ChainFiles failed: %v", err)
+	}
+	want := []string{"testdata/backend.yml", "testdata/team.yml"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ChainFiles = %v, want %v", files, want)
+	}
+
+	if names := ChainNames(files); !reflect.DeepEqual(names, []string{"backend", "team"}) {
+		t.Errorf("ChainNames = %v, want [backend team]", names)
+	}
+
+	files, err = ChainFiles("testdata/loop_a.yml")
+	if err != nil {
+		t.Fatalf("ChainFiles on a circular chain failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("ChainFiles = %v, want each file once", files)
+	}
+}
@@ -0,0 +1,159 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry is a named profile found on the search path
+type Entry struct {
+	Name string
+	Path string
+}
+
+// SearchPath returns the directories searched for named profiles, in order:
+// the profiles directory next to each prompts root in promptsDir (the last
+// root first), ./profiles, profiles at the root of the enclosing git
+// repository, and $XDG_CONFIG_HOME/ppc/profiles.
+// Duplicates are dropped; directories need not exist.
+func SearchPath(promptsDir string) []string {
+	return SearchPathIn("", promptsDir)
+}
+
+// SearchPathIn is SearchPath with ./profiles and the git repository taken
+// relative to dir rather than the working directory
+func SearchPathIn(dir, promptsDir string) []string {
+	var dirs []string
+	roots := filepath.SplitList(promptsDir)
+	for i := len(roots) - 1; i >= 0; i-- {
+		dirs = append(dirs, filepath.Join(filepath.Dir(filepath.Clean(roots[i])), "profiles"))
+	}
+	dirs = append(dirs, filepath.Join(dir, "profiles"))
+	if root := repoRoot(dir); root != "" {
+		dirs = append(dirs, filepath.Join(root, "profiles"))
+	}
+	if cfg := configHome(); cfg != "" {
+		dirs = append(dirs, filepath.Join(cfg, "ppc", "profiles"))
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	for _, d := range dirs {
+		key := d
+		if abs, err := filepath.Abs(d); err == nil {
+			key = abs
+		}
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// IsPath reports whether a --profile value names a file rather than a profile
+func IsPath(ref string) bool {
+	ext := strings.ToLower(filepath.Ext(ref))
+	return strings.ContainsRune(ref, '/') || strings.ContainsRune(ref, filepath.Separator) ||
+		ext == ".yml" || ext == ".yaml"
+}
+
+// Find resolves a profile reference to a file. Paths are returned as-is;
+// names are looked up as <dir>/<name>.yml along SearchPath(promptsDir).
+func Find(ref, promptsDir string) (string, error) {
+	return FindIn("", ref, promptsDir)
+}
+
+// FindIn is Find with relative paths and the search path taken relative to
+// dir rather than the working directory
+func FindIn(dir, ref, promptsDir string) (string, error) {
+	if IsPath(ref) {
+		if dir == "" || filepath.IsAbs(ref) {
+			return ref, nil
+		}
+		return filepath.Join(dir, ref), nil
+	}
+
+	dirs := SearchPathIn(dir, promptsDir)
+	for _, d := range dirs {
+		p := filepath.Join(d, ref+".yml")
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("profile %q not found (searched: %s)", ref, strings.Join(dirs, ", "))
+}
+
+// Resolve finds and loads a profile by name or path
+func Resolve(ref, promptsDir string) (*Profile, string, error) {
+	path, err := Find(ref, promptsDir)
+	if err != nil {
+		return nil, "", err
+	}
+	p, err := LoadProfileFromFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return p, path, nil
+}
+
+// List returns every profile on the search path, sorted by name.
+// A name found in an earlier directory shadows the same name in later ones.
+func List(promptsDir string) []Entry {
+	byName := map[string]Entry{}
+	for _, d := range SearchPath(promptsDir) {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".yml") {
+				continue
+			}
+			name := strings.TrimSuffix(e.Name(), ".yml")
+			if _, exists := byName[name]; !exists {
+				byName[name] = Entry{Name: name, Path: filepath.Join(d, e.Name())}
+			}
+		}
+	}
+
+	out := make([]Entry, 0, len(byName))
+	for _, e := range byName {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// repoRoot returns the nearest ancestor of dir (the working directory if
+// empty) that contains .git, or "" outside a repository
+func repoRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func configHome() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return xdg
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config")
+}
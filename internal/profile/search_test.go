@@ -0,0 +1,98 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProfile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".yml"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestFindSearchOrder(t *testing.T) {
+	root := t.TempDir()
+	xdg := filepath.Join(root, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	chdir(t, root)
+
+	promptsDir := filepath.Join("repo", "prompts")
+	writeProfile(t, filepath.Join("repo", "profiles"), "ship", "mode: ship\ncontract: code\n")
+	writeProfile(t, "profiles", "ship", "mode: ship\ncontract: markdown\n")
+	writeProfile(t, "profiles", "build", "mode: build\ncontract: code\n")
+	writeProfile(t, filepath.Join(xdg, "ppc", "profiles"), "global", "mode: explore\ncontract: markdown\n")
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"ship", filepath.Join("repo", "profiles", "ship.yml")},
+		{"build", filepath.Join("profiles", "build.yml")},
+		{"global", filepath.Join(xdg, "ppc", "profiles", "global.yml")},
+		{"./custom.yml", "./custom.yml"},
+	}
+	for _, tc := range tests {
+		got, err := Find(tc.ref, promptsDir)
+		if err != nil {
+			t.Fatalf("Find(%q) failed: %v", tc.ref, err)
+		}
+		if got != tc.want {
+			t.Errorf("Find(%q) = %q, want %q", tc.ref, got, tc.want)
+		}
+	}
+
+	if _, err := Find("missing", promptsDir); err == nil || !strings.Contains(err.Error(), "searched") {
+		t.Errorf("expected not-found error listing search path, got %v", err)
+	}
+
+	entries := List(promptsDir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	if strings.Join(names, ",") != "build,global,ship" {
+		t.Errorf("List names = %v, want [build global ship]", names)
+	}
+	for _, e := range entries {
+		if e.Name == "ship" && e.Path != filepath.Join("repo", "profiles", "ship.yml") {
+			t.Errorf("ship should come from next to prompts dir, got %q", e.Path)
+		}
+	}
+}
+
+func TestIsPath(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"ship", false},
+		{"ship.yml", true},
+		{"./ship", true},
+		{"team/ship", true},
+		{"custom.yaml", true},
+	}
+	for _, tc := range tests {
+		if got := IsPath(tc.ref); got != tc.want {
+			t.Errorf("IsPath(%q) = %v, want %v", tc.ref, got, tc.want)
+		}
+	}
+}
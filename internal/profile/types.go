@@ -3,14 +3,23 @@ package profile
 import (
 	"fmt"
 	"strings"
+
+	"github.com/bkuri/ppc/internal/substitute"
 )
 
 type Profile struct {
-	Mode      string         `yaml:"mode"`
-	Contract  string         `yaml:"contract"`
-	Revisions *int           `yaml:"revisions,omitempty"`
-	Traits    []string       `yaml:"traits,omitempty"`
-	Vars      map[string]any `yaml:"vars,omitempty"`
+	Extends    string         `yaml:"extends,omitempty"`
+	Mode       string         `yaml:"mode"`
+	Contract   string         `yaml:"contract"`
+	Revisions  *int           `yaml:"revisions,omitempty"`
+	Traits     []string       `yaml:"traits,omitempty"`
+	Guardrails []string       `yaml:"guardrails,omitempty"`
+	Policies   []string       `yaml:"policies,omitempty"`
+	Vars       map[string]any `yaml:"vars,omitempty"`
+	VarsFile   string         `yaml:"vars_file,omitempty"`
+	PromptsDir string         `yaml:"prompts,omitempty"`
+	Out        string         `yaml:"out,omitempty"`
+	Hash       *bool          `yaml:"hash,omitempty"`
 }
 
 func (p *Profile) Validate() error {
@@ -20,10 +29,82 @@ func (p *Profile) Validate() error {
 	if p.Contract == "" {
 		return fmt.Errorf("profile: contract is required")
 	}
+	if p.Revisions != nil && *p.Revisions < 0 {
+		return fmt.Errorf("profile: revisions must be >= 0, got %d", *p.Revisions)
+	}
 	for _, t := range p.Traits {
 		if t != "" && !strings.HasPrefix(t, "traits/") {
 			return fmt.Errorf("profile: trait %q must be module ID (e.g., traits/conservative)", t)
 		}
 	}
+	for _, g := range p.Guardrails {
+		if g == "" || strings.Contains(g, "/") {
+			return fmt.Errorf("profile: guardrail %q must be a name (e.g., tdd) or \"all\"", g)
+		}
+	}
+	for _, pol := range p.Policies {
+		if pol == "" || strings.Contains(pol, "/") {
+			return fmt.Errorf("profile: policy %q must be a name (e.g., self_score)", pol)
+		}
+	}
 	return nil
 }
+
+// Merge overlays child on parent and returns the effective profile.
+// Scalars set in child win. Traits, guardrails and policies are parent
+// entries followed by child entries, with duplicates dropped. Vars are
+// merged recursively, child values winning.
+func Merge(parent, child *Profile) *Profile {
+	out := *parent
+	out.Extends = child.Extends
+
+	if child.Mode != "" {
+		out.Mode = child.Mode
+	}
+	if child.Contract != "" {
+		out.Contract = child.Contract
+	}
+	if child.Revisions != nil {
+		out.Revisions = child.Revisions
+	}
+	if child.VarsFile != "" {
+		out.VarsFile = child.VarsFile
+	}
+	if child.PromptsDir != "" {
+		out.PromptsDir = child.PromptsDir
+	}
+	if child.Out != "" {
+		out.Out = child.Out
+	}
+	if child.Hash != nil {
+		out.Hash = child.Hash
+	}
+
+	out.Traits = appendUnique(parent.Traits, child.Traits)
+	out.Guardrails = appendUnique(parent.Guardrails, child.Guardrails)
+	out.Policies = appendUnique(parent.Policies, child.Policies)
+	out.Vars = mergeVars(parent.Vars, child.Vars)
+
+	return &out
+}
+
+func appendUnique(base, extra []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, xs := range [][]string{base, extra} {
+		for _, x := range xs {
+			if !seen[x] {
+				seen[x] = true
+				out = append(out, x)
+			}
+		}
+	}
+	return out
+}
+
+func mergeVars(base, overlay map[string]any) map[string]any {
+	if base == nil && overlay == nil {
+		return nil
+	}
+	return substitute.Merge(base, overlay)
+}
@@ -7,22 +7,99 @@ import (
 	"github.com/bkuri/ppc/internal/substitute"
 )
 
+// Segment is the rendered body of one module
+type Segment struct {
+	Module *model.Module
+	Body   string
+	// Lines maps each line of Body to the body line it was rendered from
+	Lines []Line
+}
+
+// Line is the origin of a rendered line. BodyLine is 1-based within the
+// module body; Substituted is set when a variable was substituted into it.
+// A value containing newlines renders one body line as several lines.
+type Line struct {
+	BodyLine    int
+	Substituted bool
+}
+
+// Unresolved is an occurrence of a variable that was left unsubstituted,
+// at a 1-based line of the module body
+type Unresolved struct {
+	Name     string
+	Module   *model.Module
+	BodyLine int
+}
+
+// Options controls substitution
+type Options struct {
+	// SkipCodeBlocks passes fenced code blocks through verbatim
+	SkipCodeBlocks bool
+}
+
+// Render substitutes vars into mods and joins them. It also returns the
+// unresolved variable names, each listed once.
 func Render(mods []*model.Module, vars substitute.Vars) (string, []string) {
-	var b strings.Builder
+	segs, unresolved := Segments(mods, vars, Options{})
 	seen := map[string]bool{}
-	var unresolved []string
-	for i, m := range mods {
-		if i > 0 {
-			b.WriteString("\n\n")
+	var names []string
+	for _, u := range unresolved {
+		if !seen[u.Name] {
+			seen[u.Name] = true
+			names = append(names, u.Name)
 		}
-		body, unres := substitute.Substitute(m.Body, vars)
-		for _, u := range unres {
-			if !seen[u] {
-				seen[u] = true
-				unresolved = append(unresolved, u)
+	}
+	return Join(segs), names
+}
+
+// Segments substitutes vars into each module body, in order, one line at
+// a time. Raw regions (and, with SkipCodeBlocks, fenced code blocks) are
+// copied verbatim and raw markers dropped. It also returns every
+// unresolved occurrence, in output order.
+func Segments(mods []*model.Module, vars substitute.Vars, opts Options) ([]Segment, []Unresolved) {
+	segs := make([]Segment, 0, len(mods))
+	var unresolved []Unresolved
+	for _, m := range mods {
+		var out []string
+		var lines []Line
+		srcs := strings.Split(m.Body, "\n")
+		kinds, _ := substitute.Classify(srcs, opts.SkipCodeBlocks)
+		for i, src := range srcs {
+			switch kinds[i] {
+			case substitute.Marker:
+				continue
+			case substitute.Verbatim:
+				out = append(out, src)
+				lines = append(lines, Line{BodyLine: i + 1})
+				continue
+			}
+			rendered, unres, replaced := substitute.SubstituteCount(src, vars)
+			for _, u := range unres {
+				unresolved = append(unresolved, Unresolved{Name: u, Module: m, BodyLine: i + 1})
 			}
+			for _, l := range strings.Split(rendered, "\n") {
+				out = append(out, l)
+				lines = append(lines, Line{BodyLine: i + 1, Substituted: replaced > 0})
+			}
+		}
+		if len(lines) == 0 {
+			// a body of raw markers only still renders as one empty line
+			lines = []Line{{BodyLine: 1}}
+		}
+		body := strings.TrimRight(strings.Join(out, "\n"), "\n")
+		segs = append(segs, Segment{Module: m, Body: body, Lines: lines[:strings.Count(body, "\n")+1]})
+	}
+	return segs, unresolved
+}
+
+// Join concatenates segments into a prompt, separated by blank lines
+func Join(segs []Segment) string {
+	var b strings.Builder
+	for i, s := range segs {
+		if i > 0 {
+			b.WriteString("\n\n")
 		}
-		b.WriteString(strings.TrimRight(body, "\n"))
+		b.WriteString(s.Body)
 	}
-	return strings.TrimRight(b.String(), "\n") + "\n", unresolved
+	return strings.TrimRight(b.String(), "\n") + "\n"
 }
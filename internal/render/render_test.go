@@ -1,6 +1,7 @@
 package render
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -131,3 +132,120 @@ func TestRender(t *testing.T) {
 		}
 	})
 }
+
+func TestSegments(t *testing.T) {
+	mods := []*model.Module{
+		{Front: model.Frontmatter{ID: "base"}, Body: "Hello {{name}}.\n\n"},
+		{Front: model.Frontmatter{ID: "modes/explore"}, Body: "Missing {{other}}.\nStill {{other}}."},
+	}
+	segs, unresolved := Segments(mods, substitute.Vars{"name": "ppc"}, Options{})
+	if len(segs) != 2 {
+		t.Fatalf("got %d segments, want 2", len(segs))
+	}
+	if segs[0].Module.Front.ID != "base" || segs[0].Body != "Hello ppc." {
+		t.Errorf("segs[0] = %q %q, want base %q", segs[0].Module.Front.ID, segs[0].Body, "Hello ppc.")
+	}
+	if len(unresolved) != 2 {
+		t.Fatalf("unresolved = %v, want both occurrences of other", unresolved)
+	}
+	for i, u := range unresolved {
+		if u.Name != "other" || u.Module != mods[1] || u.BodyLine != i+1 {
+			t.Errorf("unresolved[%d] = %s %s:%d, want other modes/explore:%d", i, u.Name, u.Module.Front.ID, u.BodyLine, i+1)
+		}
+	}
+
+	out, _ := Render(mods, substitute.Vars{"name": "ppc"})
+	if Join(segs) != out {
+		t.Errorf("Join(segs) = %q, want Render output %q", Join(segs), out)
+	}
+}
+
+func TestSegmentLines(t *testing.T) {
+	mods := []*model.Module{
+		{Front: model.Frontmatter{ID: "base"}, Body: "Title\n\nSpec: {{spec}}\nEnd"},
+	}
+	segs, _ := Segments(mods, substitute.Vars{"spec": "one\ntwo"}, Options{})
+	want := []Line{
+		{BodyLine: 1},
+		{BodyLine: 2},
+		{BodyLine: 3, Substituted: true},
+		{BodyLine: 3, Substituted: true},
+		{BodyLine: 4},
+	}
+	if !reflect.DeepEqual(segs[0].Lines, want) {
+		t.Errorf("Lines = %+v, want %+v", segs[0].Lines, want)
+	}
+	if n := strings.Count(segs[0].Body, "\n") + 1; n != len(segs[0].Lines) {
+		t.Errorf("body has %d lines, Lines has %d", n, len(segs[0].Lines))
+	}
+}
+
+func TestSegmentsVerbatim(t *testing.T) {
+	mods := []*model.Module{
+		{Front: model.Frontmatter{ID: "base"}, Body: "Hi {{name}}.\n{{raw}}\nJinja: {{ user }}\n{{/raw}}\n```\n{{name}}\n```"},
+	}
+	vars := substitute.Vars{"name": "ppc"}
+
+	segs, unresolved := Segments(mods, vars, Options{})
+	if want := "Hi ppc.\nJinja: {{ user }}\n```\nppc\n```"; segs[0].Body != want {
+		t.Errorf("Body = %q, want %q", segs[0].Body, want)
+	}
+	if len(unresolved) != 0 {
+		t.Errorf("raw region reported unresolved: %v", unresolved)
+	}
+	wantLines := []Line{{1, true}, {3, false}, {5, false}, {6, true}, {7, false}}
+	if !reflect.DeepEqual(segs[0].Lines, wantLines) {
+		t.Errorf("Lines = %v, want %v", segs[0].Lines, wantLines)
+	}
+
+	segs, _ = Segments(mods, vars, Options{SkipCodeBlocks: true})
+	if want := "Hi ppc.\nJinja: {{ user }}\n```\n{{name}}\n```"; segs[0].Body != want {
+		t.Errorf("Body skipping code = %q, want %q", segs[0].Body, want)
+	}
+
+	segs, _ = Segments([]*model.Module{{Body: "{{raw}}\n{{/raw}}"}}, nil, Options{})
+	if segs[0].Body != "" || len(segs[0].Lines) != 1 {
+		t.Errorf("markers-only body = %q with %d lines, want one empty line", segs[0].Body, len(segs[0].Lines))
+	}
+}
+
+func TestSegmentsMultilineRaw(t *testing.T) {
+	mods := []*model.Module{
+		{Front: model.Frontmatter{ID: "base"}, Body: "Hi {{name}}.\n{{raw}}\n{% for x in xs %}\n  {{ x }} by {{name}}\n{% endfor %}\n{{/raw}}\nBye {{name}}."},
+	}
+	segs, unresolved := Segments(mods, substitute.Vars{"name": "ppc"}, Options{})
+	want := "Hi ppc.\n{% for x in xs %}\n  {{ x }} by {{name}}\n{% endfor %}\nBye ppc."
+	if segs[0].Body != want {
+		t.Errorf("Body = %q, want %q", segs[0].Body, want)
+	}
+	if len(unresolved) != 0 {
+		t.Errorf("raw region reported unresolved: %v", unresolved)
+	}
+	wantLines := []Line{{1, true}, {3, false}, {4, false}, {5, false}, {7, true}}
+	if !reflect.DeepEqual(segs[0].Lines, wantLines) {
+		t.Errorf("Lines = %v, want %v", segs[0].Lines, wantLines)
+	}
+	if out, _ := Render(mods, substitute.Vars{"name": "ppc"}); out != want+"\n" {
+		t.Errorf("Render = %q, want %q", out, want+"\n")
+	}
+}
+
+func TestSegmentsEscapes(t *testing.T) {
+	// \{{ is an escape: content written before escapes existed that has a
+	// backslash before a placeholder now renders the literal placeholder.
+	// \\{{ keeps one backslash and substitutes.
+	mods := []*model.Module{
+		{Front: model.Frontmatter{ID: "base"}, Body: "Tag \\{{ version }}.\nPath C:\\{{dir}}\nPath C:\\\\{{dir}}\nHi {{name}}."},
+	}
+	segs, unresolved := Segments(mods, substitute.Vars{"version": "1.0", "dir": "tmp", "name": "ppc"}, Options{})
+	if want := "Tag {{ version }}.\nPath C:{{dir}}\nPath C:\\tmp\nHi ppc."; segs[0].Body != want {
+		t.Errorf("Body = %q, want %q", segs[0].Body, want)
+	}
+	if len(unresolved) != 0 {
+		t.Errorf("escapes reported unresolved: %v", unresolved)
+	}
+	wantLines := []Line{{1, false}, {2, false}, {3, true}, {4, true}}
+	if !reflect.DeepEqual(segs[0].Lines, wantLines) {
+		t.Errorf("Lines = %v, want %v", segs[0].Lines, wantLines)
+	}
+}
@@ -0,0 +1,127 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	errtypes "github.com/bkuri/ppc/internal/error"
+	"github.com/bkuri/ppc/internal/model"
+)
+
+// Scope is what a compilation applies to: the modes and contracts in its
+// closure, by name, and the profile in use with the profiles it extends
+type Scope struct {
+	Modes     []string
+	Contracts []string
+	Profiles  []string
+}
+
+// ClosureScope returns the scope of a compilation of closureIDs under
+// profiles
+func ClosureScope(closureIDs, profiles []string) Scope {
+	s := Scope{Profiles: profiles}
+	for _, id := range closureIDs {
+		if name, ok := strings.CutPrefix(id, "modes/"); ok {
+			s.Modes = append(s.Modes, name)
+		} else if name, ok := strings.CutPrefix(id, "contracts/"); ok {
+			s.Contracts = append(s.Contracts, name)
+		}
+	}
+	sort.Strings(s.Modes)
+	sort.Strings(s.Contracts)
+	return s
+}
+
+// scopeDims pairs each applies_to list with the matching part of a scope
+func scopeDims(a model.AppliesTo, s Scope) []struct {
+	name         string
+	allowed, got []string
+} {
+	return []struct {
+		name         string
+		allowed, got []string
+	}{
+		{"modes", AppliesNames(a.Modes, "modes/"), s.Modes},
+		{"contracts", AppliesNames(a.Contracts, "contracts/"), s.Contracts},
+		{"profiles", a.Profiles, s.Profiles},
+	}
+}
+
+// ValidateAppliesTo fails when a module of the closure is compiled outside
+// its applies_to scope. The error names the module, how it was pulled in,
+// what it applies to and what is being compiled.
+func ValidateAppliesTo(selectedIDs, closureIDs []string, scope Scope, all map[string]*model.Module) error {
+	ids := append([]string{}, closureIDs...)
+	sort.Strings(ids)
+	var chains map[string][]string
+	for _, id := range ids {
+		m := all[id]
+		for _, d := range scopeDims(m.Front.AppliesTo, scope) {
+			if len(d.allowed) == 0 || overlaps(d.allowed, d.got) {
+				continue
+			}
+			if chains == nil {
+				chains = RequireChains(selectedIDs, closureIDs, all)
+			}
+			got := strings.Join(d.got, ", ")
+			if got == "" {
+				got = "none"
+			}
+			return errtypes.New(m.Path, id, fmt.Sprintf("module %s (%s) applies_to %s: %s; compiling %s: %s",
+				id, describeChain(chains[id]), d.name, strings.Join(d.allowed, ", "), d.name, got))
+		}
+	}
+	return nil
+}
+
+// ImpliedScope returns the scope a module is always compiled in: its own
+// applies_to, narrowed to itself for modes/* and contracts/* modules. A
+// nil list is unrestricted.
+func ImpliedScope(m *model.Module) Scope {
+	a := m.Front.AppliesTo
+	s := Scope{
+		Modes:     AppliesNames(a.Modes, "modes/"),
+		Contracts: AppliesNames(a.Contracts, "contracts/"),
+		Profiles:  a.Profiles,
+	}
+	if name, ok := strings.CutPrefix(m.Front.ID, "modes/"); ok {
+		s.Modes = []string{name}
+	} else if name, ok := strings.CutPrefix(m.Front.ID, "contracts/"); ok {
+		s.Contracts = []string{name}
+	}
+	return s
+}
+
+// Unsatisfiable reports the first applies_to dimension in which a module
+// compiled in scope from can never include to, e.g. a mode requiring a
+// module that applies to other modes only
+func Unsatisfiable(from Scope, to model.AppliesTo) (string, []string, bool) {
+	for _, d := range scopeDims(to, from) {
+		if len(d.allowed) > 0 && len(d.got) > 0 && !overlaps(d.allowed, d.got) {
+			return d.name, d.allowed, true
+		}
+	}
+	return "", nil, false
+}
+
+// AppliesNames strips prefix (modes/ or contracts/) from applies_to entries
+func AppliesNames(names []string, prefix string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		out = append(out, strings.TrimPrefix(n, prefix))
+	}
+	return out
+}
+
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		if Contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bkuri/ppc/internal/model"
+)
+
+func TestValidateAppliesTo(t *testing.T) {
+	all := map[string]*model.Module{
+		"modes/explore":  {Front: model.Frontmatter{ID: "modes/explore", Requires: []string{"policies/spec"}}},
+		"modes/ship":     {Front: model.Frontmatter{ID: "modes/ship"}},
+		"contracts/code": {Front: model.Frontmatter{ID: "contracts/code"}},
+		"policies/spec": {Front: model.Frontmatter{ID: "policies/spec",
+			AppliesTo: model.AppliesTo{Modes: []string{"ship", "modes/build"}}}},
+		"policies/team": {Front: model.Frontmatter{ID: "policies/team",
+			AppliesTo: model.AppliesTo{Contracts: []string{"contracts/code"}, Profiles: []string{"team"}}}},
+	}
+
+	tests := []struct {
+		name     string
+		selected []string
+		profiles []string
+		wantErr  string
+	}{
+		{"in scope", []string{"modes/ship", "policies/spec"}, nil, ""},
+		{"required outside scope", []string{"modes/explore"}, nil,
+			"module policies/spec (required via modes/explore -> policies/spec) applies_to modes: ship, build; compiling modes: explore"},
+		{"no mode compiled", []string{"policies/spec"}, nil,
+			"module policies/spec (selected) applies_to modes: ship, build; compiling modes: none"},
+		{"contract and profile", []string{"contracts/code", "policies/team"}, []string{"backend", "team"}, ""},
+		{"wrong profile", []string{"contracts/code", "policies/team"}, []string{"backend"},
+			"applies_to profiles: team; compiling profiles: backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closure, _, err := ExpandRequires(tt.selected, tt.profiles, all)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ValidateAppliesTo(tt.selected, closure, ClosureScope(closure, tt.profiles), all)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsatisfiable(t *testing.T) {
+	explore := &model.Module{Front: model.Frontmatter{ID: "modes/explore"}}
+	spec := model.AppliesTo{Modes: []string{"ship"}}
+	if dim, _, bad := Unsatisfiable(ImpliedScope(explore), spec); !bad || dim != "modes" {
+		t.Errorf("modes/explore -> ship-only module: got %q, %v; want modes, true", dim, bad)
+	}
+	policy := &model.Module{Front: model.Frontmatter{ID: "policies/any"}}
+	if _, _, bad := Unsatisfiable(ImpliedScope(policy), spec); bad {
+		t.Error("an unrestricted module can require a ship-only module")
+	}
+}
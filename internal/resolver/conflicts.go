@@ -0,0 +1,104 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	errtypes "github.com/bkuri/ppc/internal/error"
+	"github.com/bkuri/ppc/internal/model"
+)
+
+// ValidateConflicts fails when two modules of the closure conflict, in
+// either direction. The error names both modules and how each was pulled
+// in: selected, or the requires and requires_any chain from a selected
+// module.
+func ValidateConflicts(selectedIDs, closureIDs []string, all map[string]*model.Module) error {
+	a, b, ok := FindConflict(closureIDs, all)
+	if !ok {
+		return nil
+	}
+	chains := RequireChains(selectedIDs, closureIDs, all)
+	return errtypes.New(all[a].Path, a, fmt.Sprintf("conflicting modules: %s (%s) conflicts with %s (%s)",
+		a, describeChain(chains[a]), b, describeChain(chains[b])))
+}
+
+// FindConflict returns the first pair of ids, in sorted order, where one
+// module declares a conflict with the other
+func FindConflict(ids []string, all map[string]*model.Module) (string, string, bool) {
+	in := map[string]bool{}
+	for _, id := range ids {
+		in[id] = true
+	}
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+	for _, a := range sorted {
+		for _, b := range sorted {
+			if a >= b {
+				continue
+			}
+			if Contains(all[a].Front.Conflicts, b) || Contains(all[b].Front.Conflicts, a) {
+				return a, b, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// RequireChains maps each module reachable from selectedIDs to the
+// shortest chain that reaches it, starting at a selected module. Chains
+// follow requires, and requires_any edges to the candidate closureIDs
+// includes, recorded as an "(any of ...)" step; with a nil closureIDs
+// only requires are followed. Ties go to the chain that sorts first;
+// missing modules are skipped.
+func RequireChains(selectedIDs, closureIDs []string, all map[string]*model.Module) map[string][]string {
+	in := map[string]bool{}
+	for _, id := range closureIDs {
+		in[id] = true
+	}
+	chains := map[string][]string{}
+	queue := append([]string{}, selectedIDs...)
+	sort.Strings(queue)
+	for _, id := range queue {
+		chains[id] = []string{id}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		m, ok := all[id]
+		if !ok {
+			continue
+		}
+		reqs := append([]string{}, m.Front.Requires...)
+		sort.Strings(reqs)
+		for _, r := range reqs {
+			if _, seen := chains[r]; seen {
+				continue
+			}
+			if _, ok := all[r]; !ok {
+				continue
+			}
+			chains[r] = append(append([]string{}, chains[id]...), r)
+			queue = append(queue, r)
+		}
+		for _, c := range MatchIDs(m.Front.RequiresAny, all) {
+			if !in[c] {
+				continue
+			}
+			if _, seen := chains[c]; !seen {
+				step := "(any of " + strings.Join(m.Front.RequiresAny, ", ") + ")"
+				chains[c] = append(append([]string{}, chains[id]...), step, c)
+				queue = append(queue, c)
+			}
+			break
+		}
+	}
+	return chains
+}
+
+func describeChain(chain []string) string {
+	if len(chain) <= 1 {
+		return "selected"
+	}
+	return "required via " + strings.Join(chain, " -> ")
+}
@@ -0,0 +1,117 @@
+package resolver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bkuri/ppc/internal/model"
+)
+
+func TestValidateConflicts(t *testing.T) {
+	all := map[string]*model.Module{
+		"base":                {Front: model.Frontmatter{ID: "base"}},
+		"modes/build":         {Front: model.Frontmatter{ID: "modes/build", Requires: []string{"policies/review"}}},
+		"policies/review":     {Front: model.Frontmatter{ID: "policies/review", Requires: []string{"policies/self_score"}}},
+		"policies/self_score": {Front: model.Frontmatter{ID: "policies/self_score", Conflicts: []string{"contracts/code"}}},
+		"contracts/code":      {Front: model.Frontmatter{ID: "contracts/code"}},
+		"contracts/markdown":  {Front: model.Frontmatter{ID: "contracts/markdown"}},
+	}
+
+	t.Run("conflict names both modules and chains", func(t *testing.T) {
+		selected := []string{"base", "modes/build", "contracts/code"}
+		closure, _, err := ExpandRequires(selected, nil, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ValidateConflicts(selected, closure, all)
+		if err == nil {
+			t.Fatal("expected conflict error")
+		}
+		want := "conflicting modules: contracts/code (selected) conflicts with policies/self_score " +
+			"(required via modes/build -> policies/review -> policies/self_score)"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want %q", err, want)
+		}
+	})
+
+	t.Run("declared on either side", func(t *testing.T) {
+		all := map[string]*model.Module{
+			"a": {Front: model.Frontmatter{ID: "a"}},
+			"b": {Front: model.Frontmatter{ID: "b", Conflicts: []string{"a"}}},
+		}
+		if err := ValidateConflicts([]string{"a", "b"}, []string{"a", "b"}, all); err == nil {
+			t.Error("expected conflict error")
+		}
+	})
+
+	t.Run("conflict through a requires_any pick", func(t *testing.T) {
+		all := map[string]*model.Module{
+			"modes/build":     {Front: model.Frontmatter{ID: "modes/build", RequiresAny: []string{"contracts/*"}}},
+			"contracts/code":  {Front: model.Frontmatter{ID: "contracts/code"}},
+			"policies/review": {Front: model.Frontmatter{ID: "policies/review", Conflicts: []string{"contracts/code"}}},
+		}
+		selected := []string{"modes/build", "policies/review"}
+		closure := []string{"modes/build", "contracts/code", "policies/review"}
+		err := ValidateConflicts(selected, closure, all)
+		if err == nil {
+			t.Fatal("expected conflict error")
+		}
+		want := "conflicting modules: contracts/code (required via modes/build -> (any of contracts/*) -> contracts/code) " +
+			"conflicts with policies/review (selected)"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want %q", err, want)
+		}
+	})
+
+	t.Run("no conflict", func(t *testing.T) {
+		selected := []string{"base", "modes/build", "contracts/markdown"}
+		closure, _, err := ExpandRequires(selected, nil, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateConflicts(selected, closure, all); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestRequireChains(t *testing.T) {
+	all := map[string]*model.Module{
+		"a": {Front: model.Frontmatter{ID: "a", Requires: []string{"c", "b", "missing"}}},
+		"b": {Front: model.Frontmatter{ID: "b", Requires: []string{"d"}}},
+		"c": {Front: model.Frontmatter{ID: "c", Requires: []string{"d"}}},
+		"d": {Front: model.Frontmatter{ID: "d", Requires: []string{"a"}}},
+	}
+	got := RequireChains([]string{"a"}, nil, all)
+	want := map[string][]string{
+		"a": {"a"},
+		"b": {"a", "b"},
+		"c": {"a", "c"},
+		"d": {"a", "b", "d"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RequireChains = %v, want %v", got, want)
+	}
+}
+
+func TestRequireChainsRequiresAny(t *testing.T) {
+	all := map[string]*model.Module{
+		"a": {Front: model.Frontmatter{ID: "a", RequiresAny: []string{"b", "c"}}},
+		"b": {Front: model.Frontmatter{ID: "b"}},
+		"c": {Front: model.Frontmatter{ID: "c", Requires: []string{"d"}}},
+		"d": {Front: model.Frontmatter{ID: "d"}},
+	}
+	got := RequireChains([]string{"a"}, []string{"a", "c", "d"}, all)
+	want := map[string][]string{
+		"a": {"a"},
+		"c": {"a", "(any of b, c)", "c"},
+		"d": {"a", "(any of b, c)", "c", "d"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RequireChains = %v, want %v", got, want)
+	}
+	if got := RequireChains([]string{"a"}, nil, all); len(got) != 1 {
+		t.Errorf("RequireChains without a closure = %v, want only a", got)
+	}
+}
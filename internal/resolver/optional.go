@@ -0,0 +1,61 @@
+package resolver
+
+import (
+	"sort"
+
+	"github.com/bkuri/ppc/internal/model"
+)
+
+// Optional dependency kinds
+const (
+	Suggests    = "suggests"
+	RequiresAny = "requires_any"
+)
+
+// OptionalDep is a suggests or requires_any edge of a compiled module
+type OptionalDep struct {
+	From string
+	Kind string
+	// Targets is the suggested ID, or the requires_any list
+	Targets []string
+	// Match is the target included in the closure, or empty
+	Match string
+	// Missing reports a suggested module that does not exist
+	Missing bool
+}
+
+// OptionalDeps lists the suggests and requires_any edges of the modules in
+// closureIDs, sorted by module ID, and how each was resolved
+func OptionalDeps(closureIDs []string, all map[string]*model.Module) []OptionalDep {
+	in := map[string]bool{}
+	for _, id := range closureIDs {
+		in[id] = true
+	}
+	ids := append([]string{}, closureIDs...)
+	sort.Strings(ids)
+
+	var deps []OptionalDep
+	for _, id := range ids {
+		m := all[id]
+		for _, s := range m.Front.Suggests {
+			dep := OptionalDep{From: id, Kind: Suggests, Targets: []string{s}}
+			if in[s] {
+				dep.Match = s
+			} else if _, ok := all[s]; !ok {
+				dep.Missing = true
+			}
+			deps = append(deps, dep)
+		}
+		if len(m.Front.RequiresAny) > 0 {
+			dep := OptionalDep{From: id, Kind: RequiresAny, Targets: m.Front.RequiresAny}
+			for _, c := range MatchIDs(m.Front.RequiresAny, all) {
+				if in[c] {
+					dep.Match = c
+					break
+				}
+			}
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
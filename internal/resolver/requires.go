@@ -3,6 +3,7 @@ package resolver
 
 import (
 	"fmt"
+	"path"
 	"sort"
 	"strings"
 
@@ -10,9 +11,15 @@ import (
 	"github.com/bkuri/ppc/internal/model"
 )
 
-// ExpandRequires performs transitive closure of requires dependencies
+// ExpandRequires performs transitive closure of requires dependencies.
+// requires_any is resolved once the closure is known, so a module selected
+// or required elsewhere can satisfy it; otherwise the first candidate that
+// neither conflicts with the closure nor falls outside its applies_to scope
+// under profiles is pulled in, with its requires. suggests never pulls
+// modules in. Version constraints on requires edges are checked as they
+// are followed.
 // Returns (closureIDs, fromReq map, error)
-func ExpandRequires(selectedIDs []string, all map[string]*model.Module) ([]string, map[string]bool, error) {
+func ExpandRequires(selectedIDs, profiles []string, all map[string]*model.Module) ([]string, map[string]bool, error) {
 	const (
 		unvisited = 0
 		visiting  = 1
@@ -56,6 +63,9 @@ func ExpandRequires(selectedIDs []string, all map[string]*model.Module) ([]strin
 			if err := dfs(r, false); err != nil {
 				return err
 			}
+			if err := validateVersion(id, r, all); err != nil {
+				return err
+			}
 			if !Contains(selectedIDs, r) {
 				fromReq[r] = true
 			}
@@ -84,9 +94,124 @@ func ExpandRequires(selectedIDs []string, all map[string]*model.Module) ([]strin
 		}
 	}
 
+	for i := 0; i < len(out); i++ {
+		m := all[out[i]]
+		if len(m.Front.RequiresAny) == 0 {
+			continue
+		}
+		cands := MatchIDs(m.Front.RequiresAny, all)
+		if len(cands) == 0 {
+			return nil, nil, errtypes.New(m.Path, m.Front.ID, fmt.Sprintf("requires_any matches no module: %s", strings.Join(m.Front.RequiresAny, ", ")))
+		}
+		if anyIn(cands, inOut) {
+			continue
+		}
+		pick := ""
+		var rejected []string
+		for _, c := range cands {
+			if why := candidateProblem(c, out, profiles, all); why != "" {
+				rejected = append(rejected, c+" ("+why+")")
+				continue
+			}
+			pick = c
+			break
+		}
+		if pick == "" {
+			return nil, nil, errtypes.New(m.Path, m.Front.ID, fmt.Sprintf("requires_any has no usable candidate: %s", strings.Join(rejected, ", ")))
+		}
+		if err := dfs(pick, false); err != nil {
+			return nil, nil, err
+		}
+		fromReq[pick] = true
+	}
+
 	return out, fromReq, nil
 }
 
+// candidateProblem reports why requires_any candidate c cannot join
+// closure: c or a module it requires conflicts with the closure, or is
+// outside the scope the closure is compiled in. It returns "" when c fits.
+func candidateProblem(c string, closure, profiles []string, all map[string]*model.Module) string {
+	in := map[string]bool{}
+	for _, id := range closure {
+		in[id] = true
+	}
+	var added []string
+	queue := []string{c}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		m, ok := all[id]
+		if !ok || in[id] {
+			continue
+		}
+		in[id] = true
+		added = append(added, id)
+		queue = append(queue, m.Front.Requires...)
+	}
+	sort.Strings(added)
+
+	ids := append(append([]string{}, closure...), added...)
+	for _, a := range added {
+		for _, b := range ids {
+			if a != b && (Contains(all[a].Front.Conflicts, b) || Contains(all[b].Front.Conflicts, a)) {
+				return fmt.Sprintf("%s conflicts with %s", a, b)
+			}
+		}
+	}
+	scope := ClosureScope(ids, profiles)
+	for _, a := range added {
+		for _, d := range scopeDims(all[a].Front.AppliesTo, scope) {
+			if len(d.allowed) > 0 && !overlaps(d.allowed, d.got) {
+				return fmt.Sprintf("%s applies_to %s: %s", a, d.name, strings.Join(d.allowed, ", "))
+			}
+		}
+	}
+	return ""
+}
+
+// MatchIDs returns the module IDs matching ids, in order. An entry with
+// glob characters (e.g. contracts/*) matches every module ID it fits,
+// sorted; other entries match the module with that ID, if present.
+func MatchIDs(ids []string, all map[string]*model.Module) []string {
+	var out []string
+	seen := map[string]bool{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	for _, pat := range ids {
+		if !strings.ContainsAny(pat, "*?[") {
+			if _, ok := all[pat]; ok {
+				add(pat)
+			}
+			continue
+		}
+		var matches []string
+		for id := range all {
+			if ok, _ := path.Match(pat, id); ok {
+				matches = append(matches, id)
+			}
+		}
+		sort.Strings(matches)
+		for _, id := range matches {
+			add(id)
+		}
+	}
+	return out
+}
+
+func anyIn(ids []string, set map[string]bool) bool {
+	for _, id := range ids {
+		if set[id] {
+			return true
+		}
+	}
+	return false
+}
+
 func DetectCycles(all map[string]*model.Module) error {
 	const (
 		unvisited = 0
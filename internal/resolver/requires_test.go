@@ -16,7 +16,7 @@ func TestExpandRequires(t *testing.T) {
 			"c": {Front: model.Frontmatter{ID: "c"}},
 		}
 
-		closure, fromReq, err := ExpandRequires([]string{"a"}, all)
+		closure, fromReq, err := ExpandRequires([]string{"a"}, nil, all)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -53,7 +53,7 @@ func TestExpandRequires(t *testing.T) {
 			"standalone": {Front: model.Frontmatter{ID: "standalone"}},
 		}
 
-		closure, _, err := ExpandRequires([]string{"standalone"}, all)
+		closure, _, err := ExpandRequires([]string{"standalone"}, nil, all)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -71,7 +71,7 @@ func TestExpandRequires(t *testing.T) {
 			"b": {Front: model.Frontmatter{ID: "b", Requires: []string{"a"}}},
 		}
 
-		_, _, err := ExpandRequires([]string{"a"}, all)
+		_, _, err := ExpandRequires([]string{"a"}, nil, all)
 		if err == nil {
 			t.Fatal("expected error for circular dependency")
 		}
@@ -85,7 +85,7 @@ func TestExpandRequires(t *testing.T) {
 			"a": {Front: model.Frontmatter{ID: "a", Requires: []string{"nonexistent"}}},
 		}
 
-		_, _, err := ExpandRequires([]string{"a"}, all)
+		_, _, err := ExpandRequires([]string{"a"}, nil, all)
 		if err == nil {
 			t.Fatal("expected error for missing dependency")
 		}
@@ -101,7 +101,7 @@ func TestExpandRequires(t *testing.T) {
 			"shared": {Front: model.Frontmatter{ID: "shared"}},
 		}
 
-		closure, _, err := ExpandRequires([]string{"a", "b"}, all)
+		closure, _, err := ExpandRequires([]string{"a", "b"}, nil, all)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -126,7 +126,7 @@ func TestExpandRequires(t *testing.T) {
 			"d": {Front: model.Frontmatter{ID: "d"}},
 		}
 
-		closure, _, err := ExpandRequires([]string{"a"}, all)
+		closure, _, err := ExpandRequires([]string{"a"}, nil, all)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -207,3 +207,112 @@ func TestDetectCycles(t *testing.T) {
 		}
 	})
 }
+
+func TestExpandRequiresOptional(t *testing.T) {
+	all := map[string]*model.Module{
+		"modes/build":        {Front: model.Frontmatter{ID: "modes/build", RequiresAny: []string{"contracts/*"}, Suggests: []string{"policies/review"}}},
+		"contracts/code":     {Front: model.Frontmatter{ID: "contracts/code"}},
+		"contracts/markdown": {Front: model.Frontmatter{ID: "contracts/markdown"}},
+		"policies/review":    {Front: model.Frontmatter{ID: "policies/review"}},
+		"modes/plan":         {Front: model.Frontmatter{ID: "modes/plan", RequiresAny: []string{"policies/missing"}}},
+	}
+
+	t.Run("requires_any satisfied by selection", func(t *testing.T) {
+		closure, _, err := ExpandRequires([]string{"modes/build", "contracts/markdown"}, nil, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Contains(closure, "contracts/code") {
+			t.Errorf("closure = %v, contracts/code should not be pulled in", closure)
+		}
+	})
+
+	t.Run("requires_any pulls first match", func(t *testing.T) {
+		closure, fromReq, err := ExpandRequires([]string{"modes/build"}, nil, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Contains(closure, "contracts/code") || !fromReq["contracts/code"] {
+			t.Errorf("closure = %v, want contracts/code required", closure)
+		}
+		if Contains(closure, "policies/review") {
+			t.Errorf("closure = %v, suggests should not pull in modules", closure)
+		}
+	})
+
+	t.Run("requires_any matches nothing", func(t *testing.T) {
+		_, _, err := ExpandRequires([]string{"modes/plan"}, nil, all)
+		if err == nil || !strings.Contains(err.Error(), "requires_any matches no module") {
+			t.Fatalf("expected requires_any error, got %v", err)
+		}
+	})
+
+	t.Run("requires_any skips unusable candidates", func(t *testing.T) {
+		all := map[string]*model.Module{
+			"modes/build":     {Front: model.Frontmatter{ID: "modes/build", RequiresAny: []string{"contracts/*"}, Requires: []string{"policies/strict"}}},
+			"contracts/a":     {Front: model.Frontmatter{ID: "contracts/a", Conflicts: []string{"policies/strict"}}},
+			"contracts/b":     {Front: model.Frontmatter{ID: "contracts/b", AppliesTo: model.AppliesTo{Modes: []string{"plan"}}}},
+			"contracts/c":     {Front: model.Frontmatter{ID: "contracts/c", Requires: []string{"policies/loose"}}},
+			"contracts/d":     {Front: model.Frontmatter{ID: "contracts/d"}},
+			"policies/loose":  {Front: model.Frontmatter{ID: "policies/loose", Conflicts: []string{"policies/strict"}}},
+			"policies/strict": {Front: model.Frontmatter{ID: "policies/strict"}},
+		}
+		closure, fromReq, err := ExpandRequires([]string{"modes/build"}, nil, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Contains(closure, "contracts/d") || !fromReq["contracts/d"] {
+			t.Errorf("closure = %v, want contracts/d required", closure)
+		}
+		for _, id := range []string{"contracts/a", "contracts/b", "contracts/c", "policies/loose"} {
+			if Contains(closure, id) {
+				t.Errorf("closure = %v, %s should not be pulled in", closure, id)
+			}
+		}
+
+		delete(all, "contracts/d")
+		_, _, err = ExpandRequires([]string{"modes/build"}, nil, all)
+		if err == nil {
+			t.Fatal("expected requires_any error")
+		}
+		for _, want := range []string{
+			"contracts/a (contracts/a conflicts with policies/strict)",
+			"contracts/b (contracts/b applies_to modes: plan)",
+			"contracts/c (policies/loose conflicts with policies/strict)",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %q", err, want)
+			}
+		}
+	})
+
+	t.Run("requires_any respects profile scope", func(t *testing.T) {
+		all := map[string]*model.Module{
+			"modes/build": {Front: model.Frontmatter{ID: "modes/build", RequiresAny: []string{"contracts/*"}}},
+			"contracts/a": {Front: model.Frontmatter{ID: "contracts/a", AppliesTo: model.AppliesTo{Profiles: []string{"ship"}}}},
+			"contracts/b": {Front: model.Frontmatter{ID: "contracts/b"}},
+		}
+		for profile, want := range map[string]string{"ship": "contracts/a", "dev": "contracts/b"} {
+			closure, _, err := ExpandRequires([]string{"modes/build"}, []string{profile}, all)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !Contains(closure, want) {
+				t.Errorf("profile %s: closure = %v, want %s", profile, closure, want)
+			}
+		}
+	})
+
+	t.Run("optional deps report", func(t *testing.T) {
+		deps := OptionalDeps([]string{"modes/build", "contracts/markdown", "policies/review"}, all)
+		if len(deps) != 2 {
+			t.Fatalf("deps = %+v, want 2", deps)
+		}
+		if deps[0].Kind != Suggests || deps[0].Match != "policies/review" {
+			t.Errorf("deps[0] = %+v, want included suggestion", deps[0])
+		}
+		if deps[1].Kind != RequiresAny || deps[1].Match != "contracts/markdown" {
+			t.Errorf("deps[1] = %+v, want requires_any satisfied by contracts/markdown", deps[1])
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	errtypes "github.com/bkuri/ppc/internal/error"
+	"github.com/bkuri/ppc/internal/model"
+	"github.com/bkuri/ppc/internal/semver"
+)
+
+// Version edge statuses
+const (
+	VersionOK           = "ok"
+	VersionOutdated     = "outdated"
+	VersionIncompatible = "incompatible"
+	VersionUnversioned  = "unversioned"
+)
+
+// VersionEdge is a requires edge carrying a version constraint
+type VersionEdge struct {
+	From       string
+	To         string
+	Constraint string
+	// Version is the version declared by To, or empty
+	Version string
+	Status  string
+}
+
+func (e VersionEdge) String() string {
+	if e.Status == VersionUnversioned {
+		return fmt.Sprintf("%s requires %s %s, but %s declares no version", e.From, e.To, e.Constraint, e.To)
+	}
+	return fmt.Sprintf("%s requires %s %s, found %s", e.From, e.To, e.Constraint, e.Version)
+}
+
+// CheckVersion checks the version constraint, if any, that from places on
+// its requires target to. Constraints and versions were validated when
+// the modules were loaded.
+func CheckVersion(from, to string, all map[string]*model.Module) (VersionEdge, bool) {
+	constraint := all[from].Front.Constraints[to]
+	target, ok := all[to]
+	if constraint == "" || !ok {
+		return VersionEdge{}, false
+	}
+	e := VersionEdge{From: from, To: to, Constraint: constraint, Version: target.Front.Version, Status: VersionOK}
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		e.Status = VersionIncompatible
+		return e, true
+	}
+	v, err := semver.Parse(e.Version)
+	switch {
+	case e.Version == "" || err != nil:
+		e.Status = VersionUnversioned
+	case c.Below(v):
+		e.Status = VersionOutdated
+	case !c.Allows(v):
+		e.Status = VersionIncompatible
+	}
+	return e, true
+}
+
+// VersionEdges returns every constrained requires edge, sorted by source
+// then target
+func VersionEdges(all map[string]*model.Module) []VersionEdge {
+	var edges []VersionEdge
+	for id, m := range all {
+		for _, r := range m.Front.Requires {
+			if e, ok := CheckVersion(id, r, all); ok {
+				edges = append(edges, e)
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// validateVersion fails when the constraint from places on to is not met
+func validateVersion(from, to string, all map[string]*model.Module) error {
+	e, ok := CheckVersion(from, to, all)
+	if !ok || e.Status == VersionOK {
+		return nil
+	}
+	return errtypes.New(all[from].Path, from, "version constraint not met: "+e.String())
+}
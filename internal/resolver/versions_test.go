@@ -0,0 +1,56 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bkuri/ppc/internal/model"
+)
+
+func TestVersionConstraints(t *testing.T) {
+	all := map[string]*model.Module{
+		"modes/build": {Front: model.Frontmatter{ID: "modes/build",
+			Requires:    []string{"guardrails/tdd", "policies/review", "base"},
+			Constraints: map[string]string{"guardrails/tdd": ">=2.0", "policies/review": "^1.0", "base": ">=1"}}},
+		"modes/plan": {Front: model.Frontmatter{ID: "modes/plan",
+			Requires:    []string{"guardrails/tdd"},
+			Constraints: map[string]string{"guardrails/tdd": "<1.0"}}},
+		"guardrails/tdd":  {Front: model.Frontmatter{ID: "guardrails/tdd", Version: "1.4"}},
+		"policies/review": {Front: model.Frontmatter{ID: "policies/review", Version: "1.2.0"}},
+		"base":            {Front: model.Frontmatter{ID: "base"}},
+		"modes/ok": {Front: model.Frontmatter{ID: "modes/ok",
+			Requires:    []string{"policies/review"},
+			Constraints: map[string]string{"policies/review": "~1.2"}}},
+	}
+
+	t.Run("violated constraint fails", func(t *testing.T) {
+		_, _, err := ExpandRequires([]string{"modes/build"}, nil, all)
+		want := "version constraint not met: modes/build requires base >=1, but base declares no version"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("err = %v, want %q", err, want)
+		}
+	})
+
+	t.Run("satisfied constraint", func(t *testing.T) {
+		if _, _, err := ExpandRequires([]string{"modes/ok"}, nil, all); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("edge statuses", func(t *testing.T) {
+		var got []string
+		for _, e := range VersionEdges(all) {
+			got = append(got, e.From+" "+e.To+" "+e.Status)
+		}
+		want := []string{
+			"modes/build base unversioned",
+			"modes/build guardrails/tdd outdated",
+			"modes/build policies/review ok",
+			"modes/ok policies/review ok",
+			"modes/plan guardrails/tdd incompatible",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("VersionEdges =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
@@ -0,0 +1,164 @@
+// Package semver parses module versions and the version constraints used
+// in requires entries.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a MAJOR.MINOR.PATCH version. Missing parts are zero.
+type Version struct {
+	Major, Minor, Patch int
+	// parts is the number of parts written, for ^ and ~
+	parts int
+}
+
+// Parse parses a version such as 2, 2.1 or v2.1.3
+func Parse(s string) (Version, error) {
+	fields := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(fields) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q (expected MAJOR[.MINOR[.PATCH]])", s)
+	}
+	var n [3]int
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil || v < 0 || strings.HasPrefix(f, "+") {
+			return Version{}, fmt.Errorf("invalid version %q (expected MAJOR[.MINOR[.PATCH]])", s)
+		}
+		n[i] = v
+	}
+	return Version{Major: n[0], Minor: n[1], Patch: n[2], parts: len(fields)}, nil
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than w
+func (v Version) Compare(w Version) int {
+	for _, d := range [][2]int{{v.Major, w.Major}, {v.Minor, w.Minor}, {v.Patch, w.Patch}} {
+		if d[0] < d[1] {
+			return -1
+		}
+		if d[0] > d[1] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+func (c comparator) allows(v Version) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Constraint is a set of comparators that must all hold, e.g.
+// ">=2.0, <3". Supported operators are =, ==, !=, >, >=, <, <=, ^ (same
+// major version, or same minor below 1.0) and ~ (same minor version, or
+// same major when only the major is given).
+type Constraint struct {
+	raw  string
+	cmps []comparator
+}
+
+// ops is ordered so that two-character operators match first
+var ops = []string{">=", "<=", "==", "!=", ">", "<", "=", "^", "~"}
+
+// ParseConstraint parses a comma or space separated list of comparators
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		op := ""
+		for _, o := range ops {
+			if strings.HasPrefix(f, o) {
+				op = o
+				break
+			}
+		}
+		rest := f[len(op):]
+		if rest == "" && op != "" && i+1 < len(fields) {
+			i++
+			rest = fields[i]
+		}
+		v, err := Parse(rest)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %v", s, err)
+		}
+		c.cmps = append(c.cmps, expand(op, v)...)
+	}
+	if len(c.cmps) == 0 {
+		return Constraint{}, fmt.Errorf("invalid version constraint %q: empty", s)
+	}
+	return c, nil
+}
+
+// expand turns ^ and ~ into a lower and an upper bound
+func expand(op string, v Version) []comparator {
+	var upper Version
+	switch {
+	case op == "^" && v.Major > 0, op == "^" && v.parts == 1, op == "~" && v.parts == 1:
+		upper = Version{Major: v.Major + 1}
+	case op == "^" && v.Minor > 0, op == "^" && v.parts == 2, op == "~":
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	case op == "^":
+		upper = Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	case op == "==" || op == "":
+		return []comparator{{"=", v}}
+	default:
+		return []comparator{{op, v}}
+	}
+	return []comparator{{">=", v}, {"<", upper}}
+}
+
+// Allows reports whether v satisfies every comparator of c
+func (c Constraint) Allows(v Version) bool {
+	for _, cmp := range c.cmps {
+		if !cmp.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Below reports whether v is too old for c: it fails a lower bound or an
+// exact match with a higher version
+func (c Constraint) Below(v Version) bool {
+	for _, cmp := range c.cmps {
+		if cmp.allows(v) {
+			continue
+		}
+		switch cmp.op {
+		case ">=", ">", "=":
+			if v.Compare(cmp.v) <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
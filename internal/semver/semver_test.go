@@ -0,0 +1,82 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"2", "2.0.0", true},
+		{"2.1", "2.1.0", true},
+		{"v2.1.3", "2.1.3", true},
+		{"2.1.3.4", "", false},
+		{"2.x", "", false},
+		{"", "", false},
+		{"-1", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v, err := Parse(tt.in)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Parse(%q) err = %v, want ok=%v", tt.in, err, tt.ok)
+			}
+			if tt.ok && v.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, v, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		allows     bool
+		below      bool
+	}{
+		{">=2.0", "2.0.0", true, false},
+		{">=2.0", "1.9", false, true},
+		{">= 2.0, <3", "3.0", false, false},
+		{">=2.0 <3", "2.9.9", true, false},
+		{">2", "2.0.0", false, true},
+		{"<=1.4", "1.4.1", false, false},
+		{"2.1", "2.1.0", true, false},
+		{"=2.1", "2.0", false, true},
+		{"==2.1", "2.2", false, false},
+		{"!=2.1", "2.1", false, false},
+		{"^1.2", "1.9", true, false},
+		{"^1.2", "2.0", false, false},
+		{"^1.2", "1.1", false, true},
+		{"^0.2.3", "0.3.0", false, false},
+		{"^0.0.3", "0.0.4", false, false},
+		{"~1.2", "1.2.9", true, false},
+		{"~1.2", "1.3", false, false},
+		{"~1", "1.9", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q): %v", tt.constraint, err)
+			}
+			v, err := Parse(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Allows(v); got != tt.allows {
+				t.Errorf("Allows = %v, want %v", got, tt.allows)
+			}
+			if got := c.Below(v); got != tt.below {
+				t.Errorf("Below = %v, want %v", got, tt.below)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", ">=", ">=two", "~>1.0"} {
+		if _, err := ParseConstraint(bad); err == nil {
+			t.Errorf("ParseConstraint(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package sourcemap maps the lines of a compiled prompt back to the module
+// files they were rendered from.
+package sourcemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bkuri/ppc/internal/compile"
+)
+
+// Version is the source map format version
+const Version = 1
+
+// Mapping maps the output lines Start..End (1-based, inclusive) to the
+// body lines BodyStart..BodyEnd of a module. SourceLine is the line of
+// Path holding BodyStart. Substituted mappings hold output of variable
+// substitution, and cover a single body line.
+type Mapping struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Module      string `json:"module"`
+	Path        string `json:"path"`
+	BodyStart   int    `json:"body_start"`
+	BodyEnd     int    `json:"body_end"`
+	SourceLine  int    `json:"source_line"`
+	Substituted bool   `json:"substituted,omitempty"`
+}
+
+// Map is a source map for one compiled prompt. Lines outside every
+// mapping are headers or the blank lines between modules.
+type Map struct {
+	Version  int       `json:"version"`
+	File     string    `json:"file,omitempty"`
+	Hash     string    `json:"hash"`
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Build maps the lines of out, a Markdown prompt compiled with meta. Any
+// headers prepended to the prompt shift the mappings down.
+func Build(out string, meta compile.CompileMeta) (Map, error) {
+	m := Map{Version: Version, Hash: meta.Hash, Mappings: []Mapping{}}
+
+	segs := joined(meta.Segments)
+	rendered := 0
+	for i, s := range segs {
+		if i > 0 {
+			rendered++ // blank line between modules
+		}
+		rendered += len(s.Lines)
+	}
+	offset := strings.Count(out, "\n") - rendered
+	if offset < 0 {
+		return m, fmt.Errorf("output does not match the compiled modules (source maps need markdown output)")
+	}
+
+	ln := offset
+	for i, s := range segs {
+		if i > 0 {
+			ln++
+		}
+		for _, l := range s.Lines {
+			ln++
+			if n := len(m.Mappings); n > 0 && extends(m.Mappings[n-1], s, l) {
+				m.Mappings[n-1].End = ln
+				m.Mappings[n-1].BodyEnd = l.BodyLine
+				continue
+			}
+			m.Mappings = append(m.Mappings, Mapping{
+				Start:       ln,
+				End:         ln,
+				Module:      s.ID,
+				Path:        s.Path,
+				BodyStart:   l.BodyLine,
+				BodyEnd:     l.BodyLine,
+				SourceLine:  s.Line + l.BodyLine - 1,
+				Substituted: l.Substituted,
+			})
+		}
+	}
+	return m, nil
+}
+
+// joined returns the segments whose lines reach the prompt. Joining trims
+// trailing newlines from the whole prompt, so empty bodies at the end
+// leave no lines behind, not even the blank lines before them.
+func joined(segs []compile.Segment) []compile.Segment {
+	n := len(segs)
+	for n > 0 && segs[n-1].Body == "" {
+		n--
+	}
+	return segs[:n]
+}
+
+// extends reports whether line l of segment s continues mapping prev:
+// the next body line of the same module, or a further line of the same
+// substituted body line
+func extends(prev Mapping, s compile.Segment, l compile.Line) bool {
+	if prev.Module != s.ID || prev.Substituted != l.Substituted {
+		return false
+	}
+	if l.Substituted {
+		return l.BodyLine == prev.BodyEnd
+	}
+	return l.BodyLine == prev.BodyEnd+1
+}
+
+// Encode returns the source map as indented JSON
+func (m Map) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
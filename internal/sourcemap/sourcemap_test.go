@@ -0,0 +1,102 @@
+package sourcemap
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bkuri/ppc/internal/compile"
+)
+
+func testMeta() compile.CompileMeta {
+	return compile.CompileMeta{
+		Hash: "abc123",
+		Segments: []compile.Segment{
+			{
+				ID: "base", Path: "prompts/base.md", Line: 5,
+				Body:  "# Base\nRules.",
+				Lines: []compile.Line{{BodyLine: 1}, {BodyLine: 2}},
+			},
+			{
+				ID: "policies/spec", Path: "prompts/policies/spec.md", Line: 4,
+				Body: "Spec:\none\ntwo\nEnd.",
+				Lines: []compile.Line{
+					{BodyLine: 1},
+					{BodyLine: 2, Substituted: true},
+					{BodyLine: 2, Substituted: true},
+					{BodyLine: 3},
+				},
+			},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	out := "# Base\nRules.\n\nSpec:\none\ntwo\nEnd.\n"
+	m, err := Build(out, testMeta())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	want := []Mapping{
+		{Start: 1, End: 2, Module: "base", Path: "prompts/base.md", BodyStart: 1, BodyEnd: 2, SourceLine: 5},
+		{Start: 4, End: 4, Module: "policies/spec", Path: "prompts/policies/spec.md", BodyStart: 1, BodyEnd: 1, SourceLine: 4},
+		{Start: 5, End: 6, Module: "policies/spec", Path: "prompts/policies/spec.md", BodyStart: 2, BodyEnd: 2, SourceLine: 5, Substituted: true},
+		{Start: 7, End: 7, Module: "policies/spec", Path: "prompts/policies/spec.md", BodyStart: 3, BodyEnd: 3, SourceLine: 6},
+	}
+	if !reflect.DeepEqual(m.Mappings, want) {
+		t.Errorf("Mappings =\n%+v\nwant\n%+v", m.Mappings, want)
+	}
+
+	t.Run("headers shift mappings", func(t *testing.T) {
+		m, err := Build("<!-- prompt-id: sha256:abc123 -->\n\n"+out, testMeta())
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		if m.Mappings[0].Start != 3 || m.Mappings[3].End != 9 {
+			t.Errorf("Mappings = %+v, want shifted by 2", m.Mappings)
+		}
+	})
+
+	t.Run("empty trailing modules", func(t *testing.T) {
+		meta := testMeta()
+		for _, id := range []string{"guardrails/y", "guardrails/z"} {
+			meta.Segments = append(meta.Segments, compile.Segment{ID: id, Path: "prompts/" + id + ".md", Line: 4, Lines: []compile.Line{{BodyLine: 1}}})
+		}
+		m, err := Build(out, meta)
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		if !reflect.DeepEqual(m.Mappings, want) {
+			t.Errorf("Mappings =\n%+v\nwant\n%+v", m.Mappings, want)
+		}
+	})
+
+	t.Run("mismatched output", func(t *testing.T) {
+		if _, err := Build("{}\n", testMeta()); err == nil {
+			t.Error("expected error for output that is not the prompt")
+		}
+	})
+}
+
+func TestEncode(t *testing.T) {
+	m, err := Build("# Base\nRules.\n\nSpec:\none\ntwo\nEnd.\n", testMeta())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.File = "AGENTS.md"
+	b, err := m.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var got Map
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Version != Version || got.File != "AGENTS.md" || got.Hash != "abc123" || len(got.Mappings) != 4 {
+		t.Errorf("decoded map = %+v", got)
+	}
+	if strings.Count(string(b), `"substituted": true`) != 1 {
+		t.Errorf("expected one substituted mapping:\n%s", b)
+	}
+}
@@ -0,0 +1,87 @@
+package substitute
+
+import "strings"
+
+// Raw region markers. Each must sit on a line of its own; the lines in
+// between are passed through verbatim and the markers are dropped.
+const (
+	RawOpen  = "{{raw}}"
+	RawClose = "{{/raw}}"
+)
+
+// LineKind says how a body line is rendered
+type LineKind int
+
+const (
+	// Substituted lines have their placeholders replaced
+	Substituted LineKind = iota
+	// Verbatim lines are copied as-is
+	Verbatim
+	// Marker lines open or close a raw region and are not rendered
+	Marker
+)
+
+// Classify returns the kind of each line: raw markers, lines inside raw
+// regions and, with skipCode, fenced code blocks (``` or ~~~) are not
+// substituted. ok is false when a raw region is left open; it then runs
+// to the end of the body.
+func Classify(lines []string, skipCode bool) (kinds []LineKind, ok bool) {
+	kinds = make([]LineKind, len(lines))
+	raw := false
+	fence := ""
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		switch {
+		case fence != "":
+			kinds[i] = Verbatim
+			if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				fence = ""
+			}
+		case raw:
+			kinds[i] = Verbatim
+			if t == RawClose {
+				kinds[i] = Marker
+				raw = false
+			}
+		case t == RawOpen:
+			kinds[i] = Marker
+			raw = true
+		case skipCode && isFence(l):
+			kinds[i] = Verbatim
+			fence = fenceOf(t)
+		}
+	}
+	return kinds, !raw
+}
+
+// isFence reports whether l opens a fenced code block: at most three
+// spaces of indentation, then three or more backticks or tildes
+func isFence(l string) bool {
+	indent := len(l) - len(strings.TrimLeft(l, " "))
+	return indent <= 3 && fenceOf(strings.TrimSpace(l)) != ""
+}
+
+// fenceOf returns the run of fence characters starting t, if at least three
+func fenceOf(t string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(t) - len(strings.TrimLeft(t, c))
+		if n >= 3 {
+			return t[:n]
+		}
+	}
+	return ""
+}
+
+// BodyPlaceholders returns the variable paths referenced by the lines of
+// body that are substituted, sorted and each listed once
+func BodyPlaceholders(body string, skipCode bool) []string {
+	lines := strings.Split(body, "\n")
+	kinds, _ := Classify(lines, skipCode)
+	var sub []string
+	for i, l := range lines {
+		if kinds[i] == Substituted {
+			sub = append(sub, l)
+		}
+	}
+	return Placeholders(strings.Join(sub, "\n"))
+}
@@ -0,0 +1,79 @@
+package substitute
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	got, unresolved := Substitute(`Use \{{ github.sha }} for {{name}}, not \{{name}}.`, Vars{"name": "ppc"})
+	if got != "Use {{ github.sha }} for ppc, not {{name}}." {
+		t.Errorf("Substitute = %q", got)
+	}
+	if len(unresolved) != 0 {
+		t.Errorf("escaped placeholders reported unresolved: %v", unresolved)
+	}
+	if got := Placeholders(`\{{a}} {{b}}`); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Placeholders = %v, want [b]", got)
+	}
+}
+
+func TestEscapedBackslash(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`C:\\{{dir}}`, `C:\tmp`},
+		{`C:\\\{{dir}}`, `C:\{{dir}}`},
+		{`C:\\\\{{dir}}`, `C:\\tmp`},
+		{`C:\dir \\ {{dir}}`, `C:\dir \\ tmp`},
+		{`\\{{missing}}`, `\\{{missing}}`},
+	}
+	for _, tt := range tests {
+		got, _ := Substitute(tt.in, Vars{"dir": "tmp"})
+		if got != tt.want {
+			t.Errorf("Substitute(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := Placeholders(`\\{{a}} \\\{{b}}`); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Placeholders = %v, want [a]", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	S, V, M := Substituted, Verbatim, Marker
+	tests := []struct {
+		name     string
+		body     string
+		skipCode bool
+		want     []LineKind
+		ok       bool
+	}{
+		{"raw region", "a\n{{raw}}\n{{x}}\n{{/raw}}\nb", false, []LineKind{S, M, V, M, S}, true},
+		{"unclosed raw", "{{raw}}\n{{x}}", false, []LineKind{M, V}, false},
+		{"code kept by default", "```\n{{x}}\n```", false, []LineKind{S, S, S}, true},
+		{"code skipped", "a\n```yaml\n{{x}}\n```\nb", true, []LineKind{S, V, V, V, S}, true},
+		{"longer fence", "````\n```\n{{x}}\n````\nb", true, []LineKind{V, V, V, V, S}, true},
+		{"tilde fence", "~~~\n{{x}}\n~~~", true, []LineKind{V, V, V}, true},
+		{"indented code is not a fence", "    ```\n{{x}}", true, []LineKind{S, S}, true},
+		{"raw marker inside skipped code", "```\n{{raw}}\n```\n{{x}}", true, []LineKind{V, V, V, S}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Classify(strings.Split(tt.body, "\n"), tt.skipCode)
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.ok {
+				t.Errorf("Classify = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBodyPlaceholders(t *testing.T) {
+	body := "{{a}}\n{{raw}}\n{{b}}\n{{/raw}}\n```\n{{c}}\n```"
+	if got := BodyPlaceholders(body, false); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("BodyPlaceholders = %v, want [a c]", got)
+	}
+	if got := BodyPlaceholders(body, true); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("BodyPlaceholders skipping code = %v, want [a]", got)
+	}
+}
@@ -0,0 +1,163 @@
+package substitute
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variable types accepted in vars: declarations
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeBool   = "bool"
+	TypeList   = "list"
+	TypeMap    = "map"
+)
+
+// Types lists the variable types, in documentation order
+var Types = []string{TypeString, TypeInt, TypeBool, TypeList, TypeMap}
+
+// ValidType reports whether typ is a variable type; empty means string
+func ValidType(typ string) bool {
+	return typ == "" || contains(Types, typ)
+}
+
+func contains(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Coerce checks val against typ and returns it in canonical form. Strings
+// are parsed for int and bool, since --var values are always strings;
+// whole floats are accepted as int.
+func Coerce(typ string, val any) (any, error) {
+	switch typ {
+	case "", TypeString:
+		switch v := val.(type) {
+		case string:
+			return v, nil
+		case int, int64, float64, bool:
+			return formatValue(v), nil
+		}
+	case TypeInt:
+		switch v := val.(type) {
+		case int:
+			return v, nil
+		case int64:
+			return int(v), nil
+		case float64:
+			if v == float64(int64(v)) {
+				return int(v), nil
+			}
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n, nil
+			}
+		}
+	case TypeBool:
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+	case TypeList:
+		if v, ok := val.([]any); ok {
+			return v, nil
+		}
+	case TypeMap:
+		switch v := val.(type) {
+		case map[string]any:
+			return v, nil
+		case Vars:
+			return map[string]any(v), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %q (expected %s)", typ, strings.Join(Types, ", "))
+	}
+	return nil, fmt.Errorf("expected %s, got %s", typeName(typ), describe(val))
+}
+
+func typeName(typ string) string {
+	if typ == "" {
+		return TypeString
+	}
+	return typ
+}
+
+func describe(val any) string {
+	switch v := val.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case int, int64:
+		return fmt.Sprintf("int %v", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case bool:
+		return fmt.Sprintf("bool %v", v)
+	case []any:
+		return "list"
+	case map[string]any, Vars:
+		return "map"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// SetPath returns a copy of vars with the dotted path set to val. Maps
+// along the path are copied, so vars itself is not modified.
+func SetPath(vars Vars, path string, val any) Vars {
+	out := Vars{}
+	for k, v := range vars {
+		out[k] = v
+	}
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		out[head] = val
+		return out
+	}
+	var child Vars
+	switch v := out[head].(type) {
+	case map[string]any:
+		child = Vars(v)
+	case Vars:
+		child = v
+	}
+	out[head] = map[string]any(SetPath(child, rest, val))
+	return out
+}
+
+// Placeholders returns the variable paths referenced in content, sorted
+// and each listed once
+func Placeholders(content string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, m := range varPattern.FindAllStringSubmatch(content, -1) {
+		if _, escaped := unescape(m); escaped {
+			continue
+		}
+		p := strings.TrimSpace(m[2])
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Covers reports whether a declaration of name covers a reference to
+// path: the same variable, or a field of it
+func Covers(name, path string) bool {
+	return path == name || strings.HasPrefix(path, name+".")
+}
@@ -0,0 +1,72 @@
+package substitute
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		typ     string
+		val     any
+		want    any
+		wantErr string
+	}{
+		{"", "x", "x", ""},
+		{"string", 42, "42", ""},
+		{"int", "7", 7, ""},
+		{"int", 7.0, 7, ""},
+		{"int", "seven", nil, `expected int, got string "seven"`},
+		{"int", 7.5, nil, "expected int, got number 7.5"},
+		{"bool", "true", true, ""},
+		{"bool", 1, nil, "expected bool, got int 1"},
+		{"list", []any{"a"}, []any{"a"}, ""},
+		{"list", "a,b", nil, "expected list"},
+		{"map", map[string]any{"k": "v"}, map[string]any{"k": "v"}, ""},
+		{"map", []any{}, nil, "expected map, got list"},
+		{"float", 1, nil, "unknown type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			got, err := Coerce(tt.typ, tt.val)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Coerce(%q, %v) err = %v, want %q", tt.typ, tt.val, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Coerce(%q, %v): %v", tt.typ, tt.val, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Coerce(%q, %v) = %#v, want %#v", tt.typ, tt.val, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	orig := Vars{"goals": map[string]any{"target": "prod"}}
+	got := SetPath(orig, "goals.budget", 3)
+	want := Vars{"goals": map[string]any{"target": "prod", "budget": 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SetPath = %v, want %v", got, want)
+	}
+	if _, ok := orig["goals"].(map[string]any)["budget"]; ok {
+		t.Error("SetPath modified its input")
+	}
+	if got := SetPath(nil, "a.b", "c"); !reflect.DeepEqual(got, Vars{"a": map[string]any{"b": "c"}}) {
+		t.Errorf("SetPath(nil) = %v", got)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("{{ b }} and {{a.x}} and {{b}}")
+	if !reflect.DeepEqual(got, []string{"a.x", "b"}) {
+		t.Errorf("Placeholders = %v, want [a.x b]", got)
+	}
+	if !Covers("a", "a.x") || Covers("a", "ab") {
+		t.Error("Covers should match fields of a declared map only")
+	}
+}
@@ -0,0 +1,165 @@
+package substitute
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Merge returns base with overlay deep-merged over it: maps present in both
+// are merged key by key, any other overlay value replaces the base value.
+// Neither argument is modified.
+func Merge(base, overlay Vars) Vars {
+	out := Vars{}
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range overlay {
+		bm, bok := asMap(out[k])
+		om, ook := asMap(v)
+		if bok && ook {
+			out[k] = map[string]any(Merge(bm, om))
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func asMap(v any) (Vars, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return Vars(m), true
+	case Vars:
+		return m, true
+	}
+	return nil, false
+}
+
+// Leaves returns the dotted paths of every non-map value in vars, sorted.
+// An empty map is a leaf.
+func Leaves(vars Vars) []string {
+	var out []string
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		m, ok := asMap(v)
+		if !ok || len(m) == 0 {
+			out = append(out, prefix)
+			return
+		}
+		for k, child := range m {
+			walk(prefix+"."+k, child)
+		}
+	}
+	for k, v := range vars {
+		walk(k, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// EnvPath maps an environment variable name to a variable path: lowercased,
+// with a double underscore separating nested keys (PROJECT__NAME ->
+// project.name). ok is false when a key would be empty.
+func EnvPath(name string) (path string, ok bool) {
+	parts := strings.Split(strings.ToLower(name), "__")
+	for _, p := range parts {
+		if p == "" {
+			return "", false
+		}
+	}
+	return strings.Join(parts, "."), true
+}
+
+// FromEnv collects the environment entries (KEY=VALUE, as from os.Environ)
+// whose key starts with prefix, mapping the rest of the key with EnvPath.
+// names maps each variable path to the environment variable it came from.
+// Entries are applied in sorted order, so the result does not depend on
+// the order of environ.
+func FromEnv(environ []string, prefix string) (vars Vars, names map[string]string) {
+	sorted := append([]string{}, environ...)
+	sort.Strings(sorted)
+	vars, names = Vars{}, map[string]string{}
+	for _, kv := range sorted {
+		k, v, _ := strings.Cut(kv, "=")
+		rest, found := strings.CutPrefix(k, prefix)
+		if !found || rest == "" {
+			continue
+		}
+		path, ok := EnvPath(rest)
+		if !ok {
+			continue
+		}
+		vars = SetPath(vars, path, v)
+		names[path] = k
+	}
+	return vars, names
+}
+
+// ParseDotenv parses a .env file: KEY=VALUE lines, optionally prefixed by
+// export, with # comments. Values may be single-quoted (literal) or
+// double-quoted (with \n, \t, \" and \\ escapes). Keys are mapped with
+// EnvPath.
+func ParseDotenv(data []byte) (Vars, error) {
+	vars := Vars{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE, got %q", n, line)
+		}
+		path, ok := EnvPath(k)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid key %q", n, k)
+		}
+		val, err := dotenvValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		vars = SetPath(vars, path, val)
+	}
+	return vars, sc.Err()
+}
+
+func dotenvValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := closingQuote(v)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", v)
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.Index(v[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", v)
+		}
+		return v[1 : end+1], nil
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// closingQuote returns the index of the unescaped " closing v, or -1
+func closingQuote(v string) int {
+	for i := 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
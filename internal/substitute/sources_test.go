@@ -0,0 +1,73 @@
+package substitute
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := Vars{"a": 1, "team": map[string]any{"name": "core", "size": 4}, "tags": []any{"x"}}
+	overlay := Vars{"b": 2, "team": map[string]any{"size": 6}, "tags": []any{"y"}}
+	want := Vars{"a": 1, "b": 2, "team": map[string]any{"name": "core", "size": 6}, "tags": []any{"y"}}
+	if got := Merge(base, overlay); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge = %v, want %v", got, want)
+	}
+	if base["team"].(map[string]any)["size"] != 4 {
+		t.Error("Merge modified base")
+	}
+	if got := Merge(base, Vars{"team": "flat"}); got["team"] != "flat" {
+		t.Errorf("non-map overlay did not replace map: %v", got["team"])
+	}
+}
+
+func TestLeaves(t *testing.T) {
+	vars := Vars{"b": 1, "a": map[string]any{"y": 2, "x": map[string]any{}}}
+	if got, want := Leaves(vars), []string{"a.x", "a.y", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Leaves = %v, want %v", got, want)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	environ := []string{"PPC_PROJECT__NAME=ppc", "HOME=/root", "PPC_SPEC_NAME=001", "PPC_=x", "PPC_BAD____KEY=y"}
+	vars, names := FromEnv(environ, "PPC_")
+	want := Vars{"spec_name": "001", "project": map[string]any{"name": "ppc"}}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("vars = %v, want %v", vars, want)
+	}
+	wantNames := map[string]string{"spec_name": "PPC_SPEC_NAME", "project.name": "PPC_PROJECT__NAME"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names = %v, want %v", names, wantNames)
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	data := []byte(`# comment
+SPEC_NAME=001
+export GIT_ROOT = /src/app # trailing comment
+WINDOW_NAME="build \"one\"\tx"
+LITERAL='$HOME #1'
+PROJECT__NAME=ppc
+EMPTY=
+`)
+	got, err := ParseDotenv(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Vars{
+		"spec_name":   "001",
+		"git_root":    "/src/app",
+		"window_name": "build \"one\"\tx",
+		"literal":     "$HOME #1",
+		"project":     map[string]any{"name": "ppc"},
+		"empty":       "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDotenv = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"NOVALUE", "=x", `A="open`, "A__=x"} {
+		if _, err := ParseDotenv([]byte(bad)); err == nil {
+			t.Errorf("ParseDotenv(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -8,20 +8,47 @@ import (
 
 type Vars map[string]any
 
-var varPattern = regexp.MustCompile(`\{\{([^}]+)\}\}`)
+// varPattern matches a placeholder with the backslashes before it. Each
+// \\ renders as one backslash, and a remaining \ escapes the placeholder,
+// which renders as the literal {{...}}: \{{x}} is {{x}} and \\{{x}} is a
+// backslash followed by the value of x. Backslashes elsewhere are literal.
+var varPattern = regexp.MustCompile(`(\\*)\{\{([^}]+)\}\}`)
+
+// unescape splits a varPattern match into the backslashes it renders and
+// whether the placeholder itself is escaped
+func unescape(m []string) (prefix string, escaped bool) {
+	n := len(m[1])
+	return strings.Repeat(`\`, n/2), n%2 == 1
+}
 
 func Substitute(content string, vars Vars) (string, []string) {
+	out, unresolved, _ := SubstituteCount(content, vars)
+	return out, unresolved
+}
+
+// SubstituteCount is Substitute, also returning how many placeholders
+// were replaced by a value. Escapes and unresolved placeholders do not
+// count.
+func SubstituteCount(content string, vars Vars) (string, []string, int) {
 	var unresolved []string
+	replaced := 0
 	result := varPattern.ReplaceAllStringFunc(content, func(match string) string {
-		path := strings.TrimSpace(varPattern.FindStringSubmatch(match)[1])
+		m := varPattern.FindStringSubmatch(match)
+		prefix, escaped := unescape(m)
+		placeholder := match[len(m[1]):]
+		if escaped {
+			return prefix + placeholder
+		}
+		path := strings.TrimSpace(m[2])
 		val, ok := ResolvePath(vars, path)
 		if !ok {
 			unresolved = append(unresolved, path)
 			return match
 		}
-		return formatValue(val)
+		replaced++
+		return prefix + formatValue(val)
 	})
-	return result, unresolved
+	return result, unresolved, replaced
 }
 
 func ResolvePath(vars Vars, path string) (any, bool) {
@@ -0,0 +1,89 @@
+package tokens
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// BPE is a byte-level byte-pair encoding: each piece from Split starts as
+// single bytes, and the adjacent pair with the lowest rank is merged until
+// no merged pair is in the table
+type BPE struct {
+	name  string
+	ranks map[string]int
+}
+
+// ParseBPE reads a rank table in the tiktoken format: one token per line,
+// base64-encoded, followed by its rank
+func ParseBPE(name string, r io.Reader) (*BPE, error) {
+	ranks := map[string]int{}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		tok, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s: line %d: expected \"<base64 token> <rank>\"", name, line)
+		}
+		b, err := base64.StdEncoding.DecodeString(tok)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid token: %v", name, line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid rank %q", name, line, rank)
+		}
+		ranks[string(b)] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("%s: empty rank table", name)
+	}
+	return &BPE{name: name, ranks: ranks}, nil
+}
+
+func (e *BPE) Name() string { return e.name }
+
+// Count returns the number of tokens text encodes to
+func (e *BPE) Count(text string) int {
+	n := 0
+	for _, p := range Split(text) {
+		n += e.countPiece([]byte(p))
+	}
+	return n
+}
+
+func (e *BPE) countPiece(b []byte) int {
+	if _, ok := e.ranks[string(b)]; ok {
+		return 1
+	}
+	// bounds[i] is the start of part i; the last entry is len(b)
+	bounds := make([]int, len(b)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			rank, ok := e.ranks[string(b[bounds[i]:bounds[i+2]])]
+			if ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
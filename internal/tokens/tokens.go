@@ -0,0 +1,212 @@
+// Package tokens counts model tokens offline. Encodings are byte-level BPE
+// rank tables in the tiktoken format, embedded from the tables directory;
+// without a table, a heuristic estimate is used instead.
+package tokens
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Heuristic is the name of the fallback estimate, which needs no table
+const Heuristic = "heuristic"
+
+// Preferred lists the encodings Default picks, in order, when their table
+// is embedded
+var Preferred = []string{"cl100k_base", "o200k_base"}
+
+// Encoding counts the tokens of a text
+type Encoding interface {
+	Name() string
+	Count(text string) int
+}
+
+//go:embed tables
+var tables embed.FS
+
+var (
+	mu     sync.Mutex
+	loaded = map[string]Encoding{}
+)
+
+// Names lists the available encodings: the embedded tables, sorted, then
+// the heuristic
+func Names() []string {
+	var names []string
+	entries, _ := tables.ReadDir("tables")
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".tiktoken"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append(names, Heuristic)
+}
+
+// Get returns the named encoding
+func Get(name string) (Encoding, error) {
+	if name == Heuristic {
+		return heuristic{}, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if enc, ok := loaded[name]; ok {
+		return enc, nil
+	}
+	f, err := tables.Open(path.Join("tables", name+".tiktoken"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	defer f.Close()
+	enc, err := ParseBPE(name, f)
+	if err != nil {
+		return nil, err
+	}
+	loaded[name] = enc
+	return enc, nil
+}
+
+// Default returns the first Preferred encoding that is embedded, or the
+// heuristic
+func Default() Encoding {
+	for _, name := range Preferred {
+		if enc, err := Get(name); err == nil {
+			return enc
+		}
+	}
+	return heuristic{}
+}
+
+// Count counts the tokens of text with the Default encoding
+func Count(text string) int {
+	return Default().Count(text)
+}
+
+// heuristic estimates the tokens of each piece the BPE encodings would
+// merge within: one per six ASCII characters, since common words are a
+// single token, plus one per other character
+type heuristic struct{}
+
+func (heuristic) Name() string { return Heuristic }
+
+func (heuristic) Count(text string) int {
+	n := 0
+	for _, p := range Split(text) {
+		ascii, other := 0, 0
+		for _, c := range p {
+			if c < utf8.RuneSelf {
+				ascii++
+			} else {
+				other++
+			}
+		}
+		n += (ascii+5)/6 + other
+	}
+	return n
+}
+
+// Split breaks text into the pieces that BPE merges within, following the
+// cl100k_base pre-tokenizer: contractions, words with one leading
+// non-letter, runs of up to three digits, punctuation runs and whitespace.
+func Split(text string) []string {
+	r := []rune(text)
+	var pieces []string
+	for i := 0; i < len(r); {
+		n := piece(r, i)
+		pieces = append(pieces, string(r[i:i+n]))
+		i += n
+	}
+	return pieces
+}
+
+// piece returns the length of the piece starting at r[i]
+func piece(r []rune, i int) int {
+	if n := contraction(r, i); n > 0 {
+		return n
+	}
+
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	j := i
+	if !isNewline(r[j]) && !isLetter(r[j]) && !isNumber(r[j]) {
+		j++
+	}
+	if j < len(r) && isLetter(r[j]) {
+		for j < len(r) && isLetter(r[j]) {
+			j++
+		}
+		return j - i
+	}
+
+	// \p{N}{1,3}
+	if isNumber(r[i]) {
+		j = i
+		for j < len(r) && j-i < 3 && isNumber(r[j]) {
+			j++
+		}
+		return j - i
+	}
+
+	// ' ?[^\s\p{L}\p{N}]+[\r\n]*'
+	j = i
+	if r[j] == ' ' {
+		j++
+	}
+	k := j
+	for k < len(r) && !unicode.IsSpace(r[k]) && !isLetter(r[k]) && !isNumber(r[k]) {
+		k++
+	}
+	if k > j {
+		for k < len(r) && isNewline(r[k]) {
+			k++
+		}
+		return k - i
+	}
+
+	// Whitespace: up to the last newline of the run; else all but the
+	// space before the next piece; else the whole run
+	end := i
+	lastNewline := -1
+	for end < len(r) && unicode.IsSpace(r[end]) {
+		if isNewline(r[end]) {
+			lastNewline = end
+		}
+		end++
+	}
+	switch {
+	case end == i:
+		return 1
+	case lastNewline >= 0:
+		return lastNewline + 1 - i
+	case end < len(r) && end-i > 1:
+		return end - 1 - i
+	}
+	return end - i
+}
+
+// contraction matches 's, 't, 're, 've, 'm, 'll and 'd, in any case
+func contraction(r []rune, i int) int {
+	if r[i] != '\'' || i+1 >= len(r) {
+		return 0
+	}
+	if i+2 < len(r) {
+		switch strings.ToLower(string(r[i+1 : i+3])) {
+		case "re", "ve", "ll":
+			return 3
+		}
+	}
+	switch unicode.ToLower(r[i+1]) {
+	case 's', 't', 'm', 'd':
+		return 2
+	}
+	return 0
+}
+
+func isLetter(c rune) bool  { return unicode.IsLetter(c) }
+func isNumber(c rune) bool  { return unicode.IsNumber(c) }
+func isNewline(c rune) bool { return c == '\r' || c == '\n' }
@@ -0,0 +1,137 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Hello world's", []string{"Hello", " world", "'s"}},
+		{"I'LL go", []string{"I", "'LL", " go"}},
+		{"12345", []string{"123", "45"}},
+		{"a !!\n\nb", []string{"a", " !!\n\n", "b"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"a \n  b  ", []string{"a", " \n", " ", " b", "  "}},
+		{"(x)", []string{"(x", ")"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Split(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func tinyTable(tokens ...string) string {
+	var b strings.Builder
+	for i, tok := range tokens {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), i)
+	}
+	return b.String()
+}
+
+func TestBPE(t *testing.T) {
+	enc, err := ParseBPE("tiny", strings.NewReader(tinyTable("a", "b", "c", " ", "ab", " ab", "cab")))
+	if err != nil {
+		t.Fatalf("ParseBPE failed: %v", err)
+	}
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"ab", 1},
+		{" abc", 2},  // " ab" + "c"
+		{"cab", 1},   // whole piece is a token
+		{"abab", 2},  // "ab" + "ab"
+		{"c ab", 2},  // "c" + " ab"
+		{"abcab", 2}, // "ab" + "cab", merged after both "ab"
+	}
+	for _, tt := range tests {
+		if got := enc.Count(tt.in); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "YQ==\n", "!!! 1\n", "YQ== one\n"} {
+		if _, err := ParseBPE("bad", strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseBPE(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+// Counts for cl100k_base, as reported by tiktoken
+func TestCl100kBase(t *testing.T) {
+	if got := Default().Name(); got != "cl100k_base" {
+		t.Fatalf("Default() = %s, want cl100k_base", got)
+	}
+	enc, err := Get("cl100k_base")
+	if err != nil {
+		t.Fatalf("Get(cl100k_base) failed: %v", err)
+	}
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"Hello, world!", 4},
+		{"tiktoken is great!", 6},
+		{"antidisestablishmentarianism", 6},
+		{"2 + 2 = 4", 7},
+		{"お誕生日おめでとう", 9},
+	}
+	for _, tt := range tests {
+		if got := enc.Count(tt.in); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	enc, err := Get(Heuristic)
+	if err != nil {
+		t.Fatalf("Get(heuristic) failed: %v", err)
+	}
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"Hi", 1},
+		{"Hello world", 2},
+		{"internationalization", 4},
+		{"日本語", 3},
+	}
+	for _, tt := range tests {
+		if got := enc.Count(tt.in); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	if _, err := Get("no_such_encoding"); err == nil || !strings.Contains(err.Error(), "available:") {
+		t.Errorf("Get(unknown) error = %v, want unknown encoding with list", err)
+	}
+	names := Names()
+	if names[len(names)-1] != Heuristic {
+		t.Errorf("Names() = %v, want heuristic last", names)
+	}
+	for _, name := range names {
+		if _, err := Get(name); err != nil {
+			t.Errorf("Get(%q) failed: %v", name, err)
+		}
+	}
+	if Default() == nil {
+		t.Error("Default() returned nil")
+	}
+}
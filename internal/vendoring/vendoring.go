@@ -0,0 +1,403 @@
+// Package vendoring copies module packages from local git repositories or
+// tarballs into a prompts directory and pins them in ppc.lock.
+//
+// Packages are listed in <prompts>/vendor.yml. Their modules are copied to
+// <prompts>/vendor/<name>/, keeping their layer directories, and ppc.lock
+// records the commit each package was taken from and the SHA-256 of every
+// file. Vendored module IDs are not rewritten.
+package vendoring
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ManifestFile lists the packages to vendor, relative to the prompts dir
+	ManifestFile = "vendor.yml"
+	// LockFile pins vendored packages, relative to the prompts dir
+	LockFile = "ppc.lock"
+	// Dir is the vendor tree, relative to the prompts dir
+	Dir = "vendor"
+)
+
+// Manifest is the content of vendor.yml
+type Manifest struct {
+	Packages []Package `yaml:"packages"`
+}
+
+// Package is a module package to vendor. Exactly one of Git or Tarball is
+// set; both are local paths, relative to the manifest. Path selects the
+// directory inside the source that holds the modules.
+type Package struct {
+	Name    string `yaml:"name"`
+	Git     string `yaml:"git,omitempty"`
+	Ref     string `yaml:"ref,omitempty"`
+	Tarball string `yaml:"tarball,omitempty"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+// Lock is the content of ppc.lock
+type Lock struct {
+	Packages []LockedPackage `yaml:"packages"`
+}
+
+// LockedPackage pins one vendored package. Commit is the git commit ID, or
+// sha256:<hex> of the tarball. Files maps each file, relative to the
+// package directory, to its SHA-256.
+type LockedPackage struct {
+	Name   string            `yaml:"name"`
+	Source string            `yaml:"source"`
+	Ref    string            `yaml:"ref,omitempty"`
+	Path   string            `yaml:"path,omitempty"`
+	Commit string            `yaml:"commit"`
+	Files  map[string]string `yaml:"files"`
+}
+
+// Validate checks package names and sources
+func (m *Manifest) Validate() error {
+	seen := map[string]bool{}
+	for i, p := range m.Packages {
+		if p.Name == "" || p.Name == "." || p.Name == ".." || strings.ContainsAny(p.Name, `/\`) {
+			return fmt.Errorf("packages[%d]: invalid name %q (expected a single directory name)", i, p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("packages[%d]: duplicate name %q", i, p.Name)
+		}
+		seen[p.Name] = true
+		switch {
+		case p.Git != "" && p.Tarball != "":
+			return fmt.Errorf("package %s: set either git or tarball, not both", p.Name)
+		case p.Git == "" && p.Tarball == "":
+			return fmt.Errorf("package %s: missing source (git or tarball)", p.Name)
+		case p.Git != "" && p.Ref == "":
+			return fmt.Errorf("package %s: git source requires a ref", p.Name)
+		case strings.HasPrefix(p.Ref, "-"):
+			return fmt.Errorf("package %s: invalid ref %q (must not start with -)", p.Name, p.Ref)
+		}
+	}
+	return nil
+}
+
+// LoadManifest reads the vendor.yml in promptsDir
+func LoadManifest(promptsDir string) (*Manifest, error) {
+	p := filepath.Join(promptsDir, ManifestFile)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", p, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", p, err)
+	}
+	return &m, nil
+}
+
+// ReadLock reads the ppc.lock in promptsDir
+func ReadLock(promptsDir string) (*Lock, error) {
+	p := filepath.Join(promptsDir, LockFile)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var l Lock
+	if err := yaml.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", p, err)
+	}
+	return &l, nil
+}
+
+// Vendor fetches every package in the vendor.yml of promptsDir, replaces
+// the vendor tree with their modules and writes ppc.lock. Nothing is
+// written unless every package can be fetched.
+func Vendor(promptsDir string) (*Lock, error) {
+	m, err := LoadManifest(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	contents := map[string]map[string][]byte{}
+	for _, p := range m.Packages {
+		locked, files, err := fetch(promptsDir, p)
+		if err != nil {
+			return nil, fmt.Errorf("package %s: %w", p.Name, err)
+		}
+		lock.Packages = append(lock.Packages, locked)
+		contents[p.Name] = files
+	}
+
+	dir := filepath.Join(promptsDir, Dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	for _, p := range lock.Packages {
+		for rel, b := range contents[p.Name] {
+			dst := filepath.Join(dir, p.Name, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(dst, b, 0o644); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+	header := "# Generated by ppc vendor. Do not edit.\n"
+	if err := os.WriteFile(filepath.Join(promptsDir, LockFile), append([]byte(header), b...), 0o644); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Verify checks the vendor tree of promptsDir against its ppc.lock: every
+// vendored module must be locked with a matching SHA-256, and every locked
+// file must exist. A prompts directory without a vendor tree passes.
+func Verify(promptsDir string) error {
+	dir := filepath.Join(promptsDir, Dir)
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	lockPath := filepath.Join(promptsDir, LockFile)
+	lock, err := ReadLock(promptsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s exists but %s is missing (run ppc vendor)", dir, lockPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	want := map[string]string{}
+	for _, p := range lock.Packages {
+		for rel, sum := range p.Files {
+			want[path.Join(p.Name, rel)] = sum
+		}
+	}
+
+	found := map[string]bool{}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isModule(p) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		sum, ok := want[rel]
+		if !ok {
+			return fmt.Errorf("vendored file %s is not in %s (run ppc vendor)", p, lockPath)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if sha256Hex(b) != sum {
+			return fmt.Errorf("vendored file %s does not match %s (run ppc vendor)", p, lockPath)
+		}
+		found[rel] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for rel := range want {
+		if !found[rel] {
+			missing = append(missing, rel)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("vendored files listed in %s are missing: %s (run ppc vendor)", lockPath, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Split splits a path relative to the prompts directory into the vendored
+// package name and the path inside the package. ok is false for paths
+// outside the vendor tree.
+func Split(relPath string) (pkg, inner string, ok bool) {
+	parts := strings.SplitN(filepath.ToSlash(relPath), "/", 3)
+	if len(parts) != 3 || parts[0] != Dir {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// sourcePath resolves a git or tarball source relative to promptsDir,
+// leaving absolute paths unchanged
+func sourcePath(promptsDir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(promptsDir, p)
+}
+
+// fetch reads the modules of p, keyed by path inside the package
+func fetch(promptsDir string, p Package) (LockedPackage, map[string][]byte, error) {
+	locked := LockedPackage{Name: p.Name, Ref: p.Ref, Path: p.Path}
+	sub := strings.Trim(path.Clean("/"+filepath.ToSlash(p.Path)), "/")
+
+	var files map[string][]byte
+	var err error
+	if p.Git != "" {
+		locked.Source = p.Git
+		locked.Commit, err = gitCommit(sourcePath(promptsDir, p.Git), p.Ref)
+		if err != nil {
+			return locked, nil, err
+		}
+		files, err = gitFiles(sourcePath(promptsDir, p.Git), locked.Commit, sub)
+	} else {
+		locked.Source = p.Tarball
+		var raw []byte
+		raw, err = os.ReadFile(sourcePath(promptsDir, p.Tarball))
+		if err != nil {
+			return locked, nil, err
+		}
+		locked.Commit = "sha256:" + sha256Hex(raw)
+		files, err = readTarball(raw, sub)
+	}
+	if err != nil {
+		return locked, nil, err
+	}
+	if len(files) == 0 {
+		return locked, nil, fmt.Errorf("no modules found under %q", p.Path)
+	}
+
+	locked.Files = make(map[string]string, len(files))
+	for rel, b := range files {
+		locked.Files[rel] = sha256Hex(b)
+	}
+	return locked, files, nil
+}
+
+// gitCommit resolves ref to a commit ID. A ref starting with - would be
+// read by git as an option, so it is rejected.
+func gitCommit(repo, ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid ref %q (must not start with -)", ref)
+	}
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown ref %q in %s", ref, repo)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func gitFiles(repo, commit, sub string) (map[string][]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", repo, "archive", "--format=tar", commit)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git archive failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return readTar(bytes.NewReader(out), sub)
+}
+
+// readTarball reads a .tar or gzip-compressed tarball
+func readTarball(raw []byte, sub string) (map[string][]byte, error) {
+	var r io.Reader = bytes.NewReader(raw)
+	if len(raw) > 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return readTar(r, sub)
+}
+
+// readTar collects the modules under sub, keyed by their path relative
+// to sub. Markdown files without module frontmatter are skipped.
+func readTar(r io.Reader, sub string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+h.Name), "/")
+		if sub != "" {
+			if !strings.HasPrefix(name, sub+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(name, sub+"/")
+		}
+		if !isModule(name) {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if !declaresModule(b) {
+			continue
+		}
+		files[name] = b
+	}
+}
+
+func isModule(p string) bool {
+	return strings.HasSuffix(strings.ToLower(p), ".md")
+}
+
+// declaresModule reports whether a Markdown file is a module rather than
+// documentation such as a README: it opens with frontmatter that declares
+// an id. Frontmatter that is not valid YAML counts as a module, so loading
+// reports the error instead of the file silently going missing.
+func declaresModule(b []byte) bool {
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+	rest, ok := strings.CutPrefix(s, "---\n")
+	if !ok {
+		return false
+	}
+	yml, _, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return true
+	}
+	var fm struct {
+		ID string `yaml:"id"`
+	}
+	if err := yaml.Unmarshal([]byte(yml), &fm); err != nil {
+		return true
+	}
+	return strings.TrimSpace(fm.ID) != ""
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,214 @@
+package vendoring
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func gzipTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		h := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVendor(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmp := t.TempDir()
+
+	repo := filepath.Join(tmp, "shared")
+	writeFile(t, filepath.Join(repo, "README.md"), "not a module\n")
+	writeFile(t, filepath.Join(repo, "prompts/guardrails/tdd.md"), "---\nid: guardrails/tdd\n---\nv1\n")
+	git(t, repo, "init", "-q")
+	git(t, repo, "add", ".")
+	git(t, repo, "commit", "-qm", "v1")
+	git(t, repo, "tag", "v1")
+	commit := git(t, repo, "rev-parse", "HEAD")
+	writeFile(t, filepath.Join(repo, "prompts/guardrails/tdd.md"), "---\nid: guardrails/tdd\n---\nv2\n")
+	git(t, repo, "commit", "-qam", "v2")
+
+	tarball := filepath.Join(tmp, "extras.tar.gz")
+	if err := os.WriteFile(tarball, gzipTar(t, map[string]string{
+		"extras-1.0/policies/review.md": "---\nid: policies/review\n---\nReview.\n",
+		"extras-1.0/README.md":          "# Extras\n\nShared review policies.\n",
+		"extras-1.0/docs/usage.md":      "---\ntitle: Usage\n---\nHow to use the extras.\n",
+	}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts := filepath.Join(tmp, "prompts")
+	writeFile(t, filepath.Join(prompts, ManifestFile), `packages:
+  - name: shared
+    git: ../shared
+    ref: v1
+    path: prompts
+  - name: extras
+    tarball: ../extras.tar.gz
+    path: extras-1.0
+`)
+
+	lock, err := Vendor(prompts)
+	if err != nil {
+		t.Fatalf("Vendor failed: %v", err)
+	}
+	if len(lock.Packages) != 2 {
+		t.Fatalf("got %d locked packages, want 2", len(lock.Packages))
+	}
+	if lock.Packages[0].Commit != commit {
+		t.Errorf("shared commit = %s, want %s", lock.Packages[0].Commit, commit)
+	}
+	if !strings.HasPrefix(lock.Packages[1].Commit, "sha256:") {
+		t.Errorf("extras commit = %s, want sha256: prefix", lock.Packages[1].Commit)
+	}
+
+	b, err := os.ReadFile(filepath.Join(prompts, "vendor/shared/guardrails/tdd.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "v1") {
+		t.Errorf("vendored tdd.md = %q, want content at ref v1", b)
+	}
+	if _, err := os.Stat(filepath.Join(prompts, "vendor/shared/README.md")); err == nil {
+		t.Error("files outside path should not be vendored")
+	}
+	if _, err := os.Stat(filepath.Join(prompts, "vendor/extras/policies/review.md")); err != nil {
+		t.Errorf("tarball module not vendored: %v", err)
+	}
+	for _, doc := range []string{"README.md", "docs/usage.md"} {
+		if _, ok := lock.Packages[1].Files[doc]; ok {
+			t.Errorf("%s has no module frontmatter but was vendored", doc)
+		}
+	}
+
+	if err := Verify(prompts); err != nil {
+		t.Fatalf("Verify after vendor failed: %v", err)
+	}
+
+	t.Run("absolute sources", func(t *testing.T) {
+		abs := filepath.Join(tmp, "abs-prompts")
+		writeFile(t, filepath.Join(abs, ManifestFile), "packages:\n"+
+			"  - name: shared\n    git: "+repo+"\n    ref: v1\n    path: prompts\n"+
+			"  - name: extras\n    tarball: "+tarball+"\n    path: extras-1.0\n")
+		if _, err := Vendor(abs); err != nil {
+			t.Fatalf("Vendor with absolute sources failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(abs, "vendor/extras/policies/review.md")); err != nil {
+			t.Errorf("tarball module not vendored: %v", err)
+		}
+	})
+
+	t.Run("gitCommit rejects option-like refs", func(t *testing.T) {
+		if _, err := gitCommit(repo, "--output=x"); err == nil || !strings.Contains(err.Error(), "must not start with -") {
+			t.Errorf("gitCommit = %v, want invalid ref error", err)
+		}
+	})
+
+	t.Run("tampered file", func(t *testing.T) {
+		p := filepath.Join(prompts, "vendor/shared/guardrails/tdd.md")
+		writeFile(t, p, "---\nid: guardrails/tdd\n---\nedited\n")
+		err := Verify(prompts)
+		if err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("Verify = %v, want hash mismatch", err)
+		}
+		writeFile(t, p, string(b))
+	})
+
+	t.Run("unlocked file", func(t *testing.T) {
+		p := filepath.Join(prompts, "vendor/shared/guardrails/extra.md")
+		writeFile(t, p, "---\nid: guardrails/extra\n---\n")
+		defer os.Remove(p)
+		if err := Verify(prompts); err == nil || !strings.Contains(err.Error(), "not in") {
+			t.Errorf("Verify = %v, want unlocked file error", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		p := filepath.Join(prompts, "vendor/extras/policies/review.md")
+		raw, _ := os.ReadFile(p)
+		os.Remove(p)
+		defer writeFile(t, p, string(raw))
+		if err := Verify(prompts); err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("Verify = %v, want missing file error", err)
+		}
+	})
+}
+
+func TestManifestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkg     Package
+		wantErr string
+	}{
+		{"nested name", Package{Name: "a/b", Tarball: "x.tar"}, "invalid name"},
+		{"no source", Package{Name: "a"}, "missing source"},
+		{"both sources", Package{Name: "a", Git: "r", Ref: "v1", Tarball: "x.tar"}, "not both"},
+		{"git without ref", Package{Name: "a", Git: "r"}, "requires a ref"},
+		{"option-like ref", Package{Name: "a", Git: "r", Ref: "--output=/tmp/x"}, "must not start with -"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Manifest{Packages: []Package{tt.pkg}}
+			err := m.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	pkg, inner, ok := Split("vendor/shared/guardrails/tdd.md")
+	if !ok || pkg != "shared" || inner != "guardrails/tdd.md" {
+		t.Errorf("Split = %q, %q, %v", pkg, inner, ok)
+	}
+	if _, _, ok := Split("guardrails/tdd.md"); ok {
+		t.Error("Split should reject paths outside vendor/")
+	}
+}
@@ -0,0 +1,63 @@
+// Package watch detects changes to files and directories by polling.
+package watch
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// Interval is the default polling interval
+const Interval = 300 * time.Millisecond
+
+// Snapshot records the size and modification time of every file under a
+// set of paths. Missing paths are left out, so creating one is a change.
+type Snapshot map[string]string
+
+// Take walks paths, which may be files or directories, and records every
+// regular file found
+func Take(paths []string) Snapshot {
+	s := Snapshot{}
+	for _, root := range paths {
+		if root == "" {
+			continue
+		}
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			s[p] = fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	return s
+}
+
+// Equal reports whether s and o record the same files in the same state
+func (s Snapshot) Equal(o Snapshot) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for p, v := range s {
+		if o[p] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Wait blocks until the files under paths differ from since, checking every
+// interval, and returns the new snapshot
+func Wait(paths []string, since Snapshot, interval time.Duration) Snapshot {
+	for {
+		time.Sleep(interval)
+		if now := Take(paths); !now.Equal(since) {
+			return now
+		}
+	}
+}
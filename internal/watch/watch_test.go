@@ -0,0 +1,72 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	dir := t.TempDir()
+	mod := filepath.Join(dir, "modes", "build.md")
+	if err := os.MkdirAll(filepath.Dir(mod), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mod, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vars := filepath.Join(t.TempDir(), "vars.yml")
+
+	before := Take([]string{dir, vars})
+	if len(before) != 1 {
+		t.Fatalf("got %d files, want 1 (missing paths are skipped)", len(before))
+	}
+	if !before.Equal(Take([]string{dir, vars})) {
+		t.Error("snapshots of unchanged files should be equal")
+	}
+
+	t.Run("modified file", func(t *testing.T) {
+		if err := os.WriteFile(mod, []byte("ab"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if before.Equal(Take([]string{dir, vars})) {
+			t.Error("expected change after modifying a file")
+		}
+	})
+
+	t.Run("created file", func(t *testing.T) {
+		current := Take([]string{dir, vars})
+		if err := os.WriteFile(vars, []byte("x: 1"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if current.Equal(Take([]string{dir, vars})) {
+			t.Error("expected change after creating a watched file")
+		}
+	})
+}
+
+func TestWait(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "rules.yml")
+	if err := os.WriteFile(p, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	since := Take([]string{dir})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.WriteFile(p, []byte("changed"), 0o644)
+	}()
+
+	done := make(chan Snapshot)
+	go func() { done <- Wait([]string{dir}, since, 5*time.Millisecond) }()
+	select {
+	case now := <-done:
+		if now.Equal(since) {
+			t.Error("Wait returned an unchanged snapshot")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not detect the change")
+	}
+}
@@ -0,0 +1,162 @@
+package ppc
+
+import (
+	"github.com/bkuri/ppc/internal/compile"
+)
+
+// Options selects the modules to compile and the variables to substitute.
+//
+// By default base, modes/Mode and contracts/Contract are always selected.
+// When Select is set, only the listed module IDs are selected, plus base if
+// IncludeBase is set and the mode or contract if Mode or Contract is set.
+//
+// MaxTokens fails the compile when the prompt has more tokens; zero uses
+// lint.max_tokens from rules.yml and a negative value disables the check.
+//
+// VarsFile and then VarsFiles are deep-merged in order; .env, .env.* and
+// *.env files are read as dotenv files, others as YAML. VarsEnv merges the
+// environment variables starting with that prefix over them, and Vars
+// takes precedence over both.
+//
+// PromptsDir may list several prompts roots, in precedence order, separated
+// by os.PathListSeparator. A module in a later root replaces one from an
+// earlier root only when it declares overrides: <id>.
+type Options struct {
+	PromptsDir  string
+	Mode        string
+	Contract    string
+	Traits      []string
+	Guardrails  []string
+	Policies    []string
+	VarsFile    string
+	VarsFiles   []string
+	VarsEnv     string
+	Vars        map[string]any
+	Select      []string
+	IncludeBase bool
+	MaxTokens   int
+	// Profiles names the profile in use and those it extends; modules
+	// with applies_to profiles only compile when one of them is listed
+	Profiles []string
+	// StrictVars makes any unresolved placeholder a compile error
+	StrictVars bool
+}
+
+// Meta describes how a prompt was resolved
+type Meta struct {
+	SelectedIDs []string
+	ClosureIDs  []string
+	Order       []string
+	Hash        string
+	// UnresolvedVars lists the names of unresolved variables, each once;
+	// Unresolved locates every occurrence
+	UnresolvedVars []string
+	Unresolved     []UnresolvedVar
+	Sources        []Source
+	RulesHash      string
+	// Segments holds each module's rendered body, in Order. The prompt is
+	// the bodies joined by blank lines.
+	Segments []Segment
+	// Tokens is the token count of the prompt in Encoding
+	Tokens   int
+	Encoding string
+	// VarSources records where each variable value came from, sorted by
+	// Name
+	VarSources []VarSource
+}
+
+// VarSource names the source of a variable value: "file:PATH",
+// "env:NAME", "var" (Options.Vars) or "default:MODULE"
+type VarSource struct {
+	Name   string
+	Source string
+}
+
+// UnresolvedVar is a placeholder left unsubstituted, at Line of Path
+type UnresolvedVar struct {
+	Name   string
+	Module string
+	Path   string
+	Line   int
+}
+
+// Segment is the rendered body of one module, after variable substitution
+type Segment struct {
+	ID     string
+	Layer  string
+	Path   string
+	Body   string
+	Tokens int
+}
+
+// Source records the file a compiled module was read from and the
+// SHA-256 of its raw content. Root is the prompts root it was loaded from.
+type Source struct {
+	ID   string
+	Path string
+	Root string
+	Hash string
+	// Version is the module's declared version, if any
+	Version string
+}
+
+// Compile resolves, orders and renders the selected modules.
+// It returns the rendered prompt and metadata about the resolution.
+func Compile(opts Options) (string, Meta, error) {
+	out, meta, err := compile.Compile(compile.CompileOptions{
+		Mode:        opts.Mode,
+		Contract:    opts.Contract,
+		Traits:      opts.Traits,
+		Guardrails:  opts.Guardrails,
+		Policies:    opts.Policies,
+		PromptsDir:  opts.PromptsDir,
+		VarsFile:    opts.VarsFile,
+		VarsFiles:   opts.VarsFiles,
+		VarsEnv:     opts.VarsEnv,
+		Vars:        opts.Vars,
+		Select:      opts.Select,
+		IncludeBase: opts.IncludeBase,
+		MaxTokens:   opts.MaxTokens,
+		Profiles:    opts.Profiles,
+		StrictVars:  opts.StrictVars,
+	})
+	if err != nil {
+		return "", Meta{}, err
+	}
+	sources := make([]Source, 0, len(meta.Sources))
+	for _, s := range meta.Sources {
+		sources = append(sources, Source{ID: s.ID, Path: s.Path, Root: s.Root, Hash: s.Hash, Version: s.Version})
+	}
+	var names []string
+	seen := map[string]bool{}
+	unresolved := make([]UnresolvedVar, 0, len(meta.UnresolvedVars))
+	for _, u := range meta.UnresolvedVars {
+		if !seen[u.Name] {
+			seen[u.Name] = true
+			names = append(names, u.Name)
+		}
+		unresolved = append(unresolved, UnresolvedVar(u))
+	}
+	varSources := make([]VarSource, 0, len(meta.VarSources))
+	for _, v := range meta.VarSources {
+		varSources = append(varSources, VarSource(v))
+	}
+	segments := make([]Segment, 0, len(meta.Segments))
+	for _, s := range meta.Segments {
+		segments = append(segments, Segment{ID: s.ID, Layer: s.Layer, Path: s.Path, Body: s.Body, Tokens: s.Tokens})
+	}
+	return out, Meta{
+		SelectedIDs:    meta.SelectedIDs,
+		ClosureIDs:     meta.ClosureIDs,
+		Order:          meta.Order,
+		Hash:           meta.Hash,
+		UnresolvedVars: names,
+		Unresolved:     unresolved,
+		Sources:        sources,
+		RulesHash:      meta.RulesHash,
+		Segments:       segments,
+		Tokens:         meta.Tokens,
+		Encoding:       meta.Encoding,
+		VarSources:     varSources,
+	}, nil
+}
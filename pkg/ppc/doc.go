@@ -0,0 +1,23 @@
+// Package ppc is the public Go API of the Prompt Policy Compiler.
+//
+// It wraps the compiler, module loader, doctor and lint engines that back
+// the ppc binary so other Go programs can embed PPC instead of shelling out.
+//
+//	out, meta, err := ppc.Compile(ppc.Options{
+//		PromptsDir: "prompts",
+//		Mode:       "build",
+//		Contract:   "code",
+//		Traits:     []string{"traits/conservative"},
+//	})
+//
+// # Compatibility
+//
+// This package follows the semantic version of the github.com/bkuri/ppc
+// module. Within a major version, exported identifiers are not removed or
+// renamed and function signatures do not change. Structs may gain new
+// fields in minor releases, so construct them with keyed literals. Output
+// for identical inputs is byte-for-byte stable within a minor release;
+// changes to it between minor releases are marked breaking in CHANGELOG.md.
+//
+// Packages under internal/ carry no such promise and may change at any time.
+package ppc
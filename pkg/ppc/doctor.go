@@ -0,0 +1,67 @@
+package ppc
+
+import (
+	"github.com/bkuri/ppc/internal/doctor"
+)
+
+// DoctorStats summarizes a prompts directory
+type DoctorStats struct {
+	Modules     int
+	ByLayer     map[string]int
+	Unreachable int
+	Tags        int
+	Groups      int
+	Orphaned    int
+	// Tokens is the token count of all module bodies in Encoding;
+	// ModuleTokens breaks it down by module ID
+	Tokens       int
+	ModuleTokens map[string]int
+	Encoding     string
+}
+
+// DoctorReport is the outcome of validating a prompts directory
+type DoctorReport struct {
+	Modules     int
+	Errors      []string
+	Warnings    []string
+	Unreachable []string
+	Stats       DoctorStats
+	// Roots maps each prompts root to the module IDs loaded from it
+	Roots map[string][]string
+}
+
+// OK reports whether the check passed. With strict set, warnings also fail.
+func (r *DoctorReport) OK(strict bool) bool {
+	if len(r.Errors) > 0 {
+		return false
+	}
+	return !strict || len(r.Warnings) == 0
+}
+
+// Doctor validates module structure, requires targets, cycles and tag rules.
+// An error is returned only when modules or rules.yml cannot be loaded.
+func Doctor(promptsDir string) (*DoctorReport, error) {
+	f, err := doctor.Diagnose(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	f.CountTokens()
+	return &DoctorReport{
+		Modules:     len(f.Modules),
+		Errors:      f.Errors,
+		Warnings:    f.Warnings,
+		Unreachable: f.Unreachable(),
+		Roots:       f.Roots(),
+		Stats: DoctorStats{
+			Modules:      f.Stats.Modules,
+			ByLayer:      f.Stats.ByLayer,
+			Unreachable:  f.Stats.Unreachable,
+			Tags:         f.Stats.Tags,
+			Groups:       f.Stats.Groups,
+			Orphaned:     f.Stats.Orphaned,
+			Tokens:       f.Stats.Tokens,
+			ModuleTokens: f.Stats.ModuleTokens,
+			Encoding:     f.Stats.Encoding,
+		},
+	}, nil
+}
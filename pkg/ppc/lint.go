@@ -0,0 +1,119 @@
+package ppc
+
+import (
+	"github.com/bkuri/ppc/internal/lint"
+	"github.com/bkuri/ppc/internal/loader"
+)
+
+// ContentPattern forbids a regular expression in module bodies
+type ContentPattern struct {
+	Match  string
+	Reason string
+	Paths  []string
+}
+
+// LintConfig holds lint thresholds and rules. Zero values leave the
+// corresponding setting from rules.yml in place.
+type LintConfig struct {
+	MaxWords              int
+	MaxLines              int
+	MaxModules            int
+	MaxModuleWords        int
+	MaxDepth              int
+	MaxTokens             int
+	RequireTags           []string
+	ForbidTags            []string
+	RequireFields         []string
+	ForbidEmptyBody       bool
+	ForbidContentPatterns []ContentPattern
+	// FailOn is the lowest severity that fails: error, warn, info or none
+	FailOn string
+}
+
+// Lint severity levels reported in Violation.Level
+const (
+	LevelError = lint.LevelError
+	LevelWarn  = lint.LevelWarn
+	LevelInfo  = lint.LevelInfo
+)
+
+// Violation is a single lint finding. Scope names the rules.yml lint
+// scope that set the violated rule, or is empty for global rules.
+type Violation struct {
+	Level   string
+	Rule    string
+	Message string
+	Module  string
+	Scope   string
+}
+
+// LintResult is the outcome of linting a prompts directory. Counts holds
+// the number of violations per severity; Failed reports whether any
+// violation reached the fail-on threshold.
+type LintResult struct {
+	Violations []Violation
+	Counts     map[string]int
+	Failed     bool
+	Stats      map[string]int
+	// Tokens maps each module ID to the token count of its body in
+	// Encoding
+	Tokens   map[string]int
+	Encoding string
+}
+
+// Lint checks promptsDir against the lint section of its rules.yml.
+// Non-zero fields in overrides take precedence over the file, the same
+// way ppc lint flags do.
+func Lint(promptsDir string, overrides LintConfig) (*LintResult, error) {
+	rules, err := loader.LoadRules(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := lint.Config{
+		MaxWords:        overrides.MaxWords,
+		MaxLines:        overrides.MaxLines,
+		MaxModules:      overrides.MaxModules,
+		MaxModuleWords:  overrides.MaxModuleWords,
+		MaxDepth:        overrides.MaxDepth,
+		MaxTokens:       overrides.MaxTokens,
+		RequireTags:     overrides.RequireTags,
+		ForbidTags:      overrides.ForbidTags,
+		RequireFields:   overrides.RequireFields,
+		ForbidEmptyBody: overrides.ForbidEmptyBody,
+		FailOn:          overrides.FailOn,
+	}
+	for _, p := range overrides.ForbidContentPatterns {
+		cli.ForbidContentPatterns = append(cli.ForbidContentPatterns, lint.ContentPattern{
+			Match:  p.Match,
+			Reason: p.Reason,
+			Paths:  p.Paths,
+		})
+	}
+
+	cfg := lint.MergeConfig(rules.Lint, cli, lint.CLISet{
+		MaxWords:       overrides.MaxWords != 0,
+		MaxLines:       overrides.MaxLines != 0,
+		MaxModules:     overrides.MaxModules != 0,
+		MaxModuleWords: overrides.MaxModuleWords != 0,
+		MaxDepth:       overrides.MaxDepth != 0,
+		MaxTokens:      overrides.MaxTokens != 0,
+	})
+
+	res, err := lint.Run(promptsDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &LintResult{Counts: res.Counts, Failed: res.Failed, Stats: res.Stats, Tokens: res.Tokens, Encoding: res.Encoding}
+	for _, v := range res.Violations {
+		out.Violations = append(out.Violations, Violation{
+			Level:   v.Level,
+			Rule:    v.Rule,
+			Message: v.Message,
+			Module:  v.Module,
+			Scope:   v.Scope,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+package ppc
+
+import (
+	"sort"
+
+	"github.com/bkuri/ppc/internal/loader"
+	"github.com/bkuri/ppc/internal/model"
+)
+
+// Module is a prompt module loaded from a prompts directory
+type Module struct {
+	ID       string
+	Desc     string
+	Path     string
+	Root     string
+	Layer    string
+	Priority int
+	Tags     []string
+	// Version is the declared version, if any
+	Version  string
+	Requires []string
+	// Constraints maps requires IDs to their version constraint
+	Constraints map[string]string
+	// Conflicts lists modules that must never be compiled with this one
+	Conflicts []string
+	// Suggests lists modules compiled alongside only if otherwise selected
+	Suggests []string
+	// RequiresAny lists module IDs or globs, at least one of which is compiled
+	RequiresAny []string
+	// AppliesTo restricts the modes, contracts and profiles the module
+	// may be compiled with
+	AppliesTo AppliesTo
+	// Vars declares the variables the body references
+	Vars map[string]VarSpec
+	Body string
+}
+
+// LoadModules loads every module under promptsDir, sorted by ID. promptsDir
+// may list several prompts roots separated by os.PathListSeparator.
+func LoadModules(promptsDir string) ([]Module, error) {
+	modByID, err := loader.LoadModules(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	order, _, err := loader.LayerOrder(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	return sortedModules(modByID, order), nil
+}
+
+func sortedModules(modByID map[string]*model.Module, order []string) []Module {
+	ids := make([]string, 0, len(modByID))
+	for id := range modByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := make([]Module, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, newModule(modByID[id], order))
+	}
+	return out
+}
+
+// AppliesTo lists the modes, contracts and profiles a module is limited
+// to; an empty list does not restrict
+type AppliesTo struct {
+	Modes     []string
+	Contracts []string
+	Profiles  []string
+}
+
+// VarSpec declares a module variable; Type is string, int, bool, list or
+// map (string when empty)
+type VarSpec struct {
+	Type        string
+	Required    bool
+	Default     any
+	Description string
+}
+
+func newVarSpecs(specs map[string]model.VarSpec) map[string]VarSpec {
+	if specs == nil {
+		return nil
+	}
+	out := make(map[string]VarSpec, len(specs))
+	for name, s := range specs {
+		out[name] = VarSpec(s)
+	}
+	return out
+}
+
+func newModule(m *model.Module, order []string) Module {
+	return Module{
+		ID:          m.Front.ID,
+		Desc:        m.Front.Desc,
+		Path:        m.Path,
+		Root:        m.Root,
+		Layer:       model.LayerNameIn(order, m.Layer),
+		Priority:    m.Front.Priority,
+		Tags:        append([]string{}, m.Front.Tags...),
+		Version:     m.Front.Version,
+		Requires:    append([]string{}, m.Front.Requires...),
+		Constraints: copyMap(m.Front.Constraints),
+		Conflicts:   append([]string{}, m.Front.Conflicts...),
+		Suggests:    append([]string{}, m.Front.Suggests...),
+		RequiresAny: append([]string{}, m.Front.RequiresAny...),
+		AppliesTo: AppliesTo{
+			Modes:     append([]string{}, m.Front.AppliesTo.Modes...),
+			Contracts: append([]string{}, m.Front.AppliesTo.Contracts...),
+			Profiles:  append([]string{}, m.Front.AppliesTo.Profiles...),
+		},
+		Vars: newVarSpecs(m.Front.Vars),
+		Body: m.Body,
+	}
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
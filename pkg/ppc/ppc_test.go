@@ -0,0 +1,137 @@
+package ppc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var promptsDir = filepath.Join("..", "..", "prompts")
+
+func TestCompile(t *testing.T) {
+	out, meta, err := Compile(Options{
+		PromptsDir: promptsDir,
+		Mode:       "explore",
+		Contract:   "markdown",
+		Traits:     []string{"traits/conservative"},
+	})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	if !strings.Contains(out, "Mode: Explore") {
+		t.Errorf("expected explore mode output, got:\n%s", out)
+	}
+	if len(meta.Hash) != 64 {
+		t.Errorf("meta.Hash length = %d, want 64", len(meta.Hash))
+	}
+	if len(meta.Order) == 0 || meta.Order[0] != "base" {
+		t.Errorf("meta.Order = %v, want base first", meta.Order)
+	}
+	if len(meta.Segments) != len(meta.Order) {
+		t.Fatalf("got %d segments for %d modules", len(meta.Segments), len(meta.Order))
+	}
+	if seg := meta.Segments[1]; seg.ID != "modes/explore" || seg.Layer != "modes" || !strings.Contains(out, seg.Body) {
+		t.Errorf("Segments[1] = %+v, want the rendered explore mode", seg)
+	}
+	if meta.Tokens == 0 || meta.Encoding == "" || meta.Segments[0].Tokens == 0 {
+		t.Errorf("Tokens = %d, Encoding = %q, Segments[0].Tokens = %d", meta.Tokens, meta.Encoding, meta.Segments[0].Tokens)
+	}
+}
+
+func TestCompileUnresolved(t *testing.T) {
+	opts := Options{
+		PromptsDir: promptsDir,
+		Mode:       "build",
+		Contract:   "markdown",
+		Policies:   []string{"spec_context"},
+		Vars:       map[string]any{"spec_name": "001"},
+	}
+	_, meta, err := Compile(opts)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	want := []string{"worktree_path", "git_root", "window_name", "spec_content"}
+	if strings.Join(meta.UnresolvedVars, ",") != strings.Join(want, ",") {
+		t.Errorf("UnresolvedVars = %v, want %v", meta.UnresolvedVars, want)
+	}
+	if len(meta.Unresolved) != len(want) || meta.Unresolved[0].Module != "policies/spec_context" || meta.Unresolved[0].Line == 0 {
+		t.Errorf("Unresolved = %+v, want located occurrences", meta.Unresolved)
+	}
+
+	opts.StrictVars = true
+	if _, _, err := Compile(opts); err == nil {
+		t.Error("expected StrictVars to fail on unresolved variables")
+	}
+}
+
+func TestCompileMissingMode(t *testing.T) {
+	_, _, err := Compile(Options{
+		PromptsDir: promptsDir,
+		Mode:       "nonexistent",
+		Contract:   "markdown",
+	})
+	if err == nil {
+		t.Fatal("expected error for missing mode module")
+	}
+}
+
+func TestLoadModules(t *testing.T) {
+	mods, err := LoadModules(promptsDir)
+	if err != nil {
+		t.Fatalf("LoadModules failed: %v", err)
+	}
+
+	for i := 1; i < len(mods); i++ {
+		if mods[i-1].ID >= mods[i].ID {
+			t.Fatalf("modules not sorted by ID: %q before %q", mods[i-1].ID, mods[i].ID)
+		}
+	}
+
+	for _, m := range mods {
+		if m.ID == "traits/terse" && m.Layer != "traits" {
+			t.Errorf("traits/terse layer = %q, want traits", m.Layer)
+		}
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	report, err := Doctor(promptsDir)
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+
+	if !report.OK(false) {
+		t.Errorf("expected doctor to pass, got errors: %v", report.Errors)
+	}
+	if report.Modules != report.Stats.Modules {
+		t.Errorf("Modules = %d, Stats.Modules = %d", report.Modules, report.Stats.Modules)
+	}
+}
+
+func TestDoctorMissingDir(t *testing.T) {
+	if _, err := Doctor(filepath.Join("testdata", "nonexistent")); err == nil {
+		t.Fatal("expected error for missing prompts directory")
+	}
+}
+
+func TestLint(t *testing.T) {
+	res, err := Lint(promptsDir, LintConfig{MaxModuleWords: 1})
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+
+	if res.Stats["module_count"] == 0 {
+		t.Error("expected module_count stat")
+	}
+
+	found := false
+	for _, v := range res.Violations {
+		if v.Rule == "max_module_words" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected max_module_words violation with threshold 1")
+	}
+}
@@ -155,3 +155,46 @@ func computeReachable(modByID map[string]*model.Module) map[string]bool {
 
 	return reachable
 }
+
+func TestGraphDeclaredLayerClusters(t *testing.T) {
+	dir := filepath.Join("..", "internal", "loader", "testdata", "custom_layers")
+	modByID, err := loader.LoadModules(dir)
+	if err != nil {
+		t.Fatalf("LoadModules failed: %v", err)
+	}
+	rules, err := loader.LoadRules(dir)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	dot := graph.BuildDOT(modByID, rules, computeReachable(modByID))
+
+	for _, cluster := range []string{"cluster_1_personas", "cluster_2_modes", "cluster_3_examples"} {
+		if !strings.Contains(dot, cluster) {
+			t.Errorf("missing %s in:\n%s", cluster, dot)
+		}
+	}
+}
+
+func TestGraphOptionalEdgeStyles(t *testing.T) {
+	dir := filepath.Join("..", "internal", "doctor", "testdata", "optional")
+	modByID, err := loader.LoadModules(dir)
+	if err != nil {
+		t.Fatalf("LoadModules failed: %v", err)
+	}
+	rules, err := loader.LoadRules(dir)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	dot := graph.BuildDOT(modByID, rules, computeReachable(modByID))
+
+	for _, edge := range []string{
+		`"modes/build" -> "guardrails/tdd" [style="dotted"];`,
+		`"modes/build" -> "policies/review" [style="dashed"];`,
+	} {
+		if !strings.Contains(dot, edge) {
+			t.Errorf("missing edge %s in:\n%s", edge, dot)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package tests
 
 import (
+	"archive/tar"
 	"bytes"
+	"encoding/json"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestBasicCompile(t *testing.T) {
@@ -32,3 +39,767 @@ func TestBasicCompile(t *testing.T) {
 		t.Fatalf("expected conservative trait, got:\n%s", s)
 	}
 }
+
+func TestModeSubcommandsFromPrompts(t *testing.T) {
+	cmd := exec.Command("./ppc", "explore", "--prompts", "examples/04-product-prd-review/prompts")
+	cmd.Dir = ".."
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out.String())
+	}
+
+	cmd = exec.Command("./ppc", "review")
+	cmd.Dir = ".."
+	out.Reset()
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected unknown mode to fail")
+	}
+	if !strings.Contains(out.String(), "available modes in prompts: build, explore, ship") {
+		t.Errorf("expected available modes listing, got:\n%s", out.String())
+	}
+}
+
+func TestModeSubcommandsMissingPrompts(t *testing.T) {
+	cmd := exec.Command("./ppc", "build", "--prompts", "does-not-exist")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected missing prompts directory to fail, got:\n%s", out)
+	}
+	if strings.Contains(string(out), "unknown subcommand") || !strings.Contains(string(out), "does-not-exist") {
+		t.Errorf("expected the prompts directory error, got:\n%s", out)
+	}
+}
+
+func TestModeSubcommandsFromProfilePrompts(t *testing.T) {
+	tmp := t.TempDir()
+	for rel, content := range map[string]string{
+		"prompts/rules.yml":             "exclusive_groups: []\n",
+		"prompts/base.md":               "---\nid: base\n---\nBase.\n",
+		"prompts/modes/review.md":       "---\nid: modes/review\n---\nReview mode.\n",
+		"prompts/contracts/markdown.md": "---\nid: contracts/markdown\n---\nMarkdown.\n",
+		"team.yml":                      "mode: review\ncontract: markdown\nprompts: prompts\n",
+	} {
+		p := filepath.Join(tmp, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("./ppc", "review", "--profile", filepath.Join(tmp, "team.yml"))
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Review mode.") {
+		t.Errorf("expected the profile's review mode, got:\n%s", out)
+	}
+}
+
+func TestProfileRevisions(t *testing.T) {
+	tmp := t.TempDir()
+	for rel, content := range map[string]string{
+		"prompts/rules.yml":             "exclusive_groups: []\n",
+		"prompts/base.md":               "---\nid: base\n---\nBase.\n",
+		"prompts/modes/review.md":       "---\nid: modes/review\n---\nReview mode.\n",
+		"prompts/contracts/markdown.md": "---\nid: contracts/markdown\n---\nMarkdown.\n",
+		"prompts/policies/revisions.md": "---\nid: policies/revisions\n---\nRevise {{revisions}} times.\n",
+		"team.yml":                      "mode: review\ncontract: markdown\nprompts: prompts\nrevisions: 2\n",
+	} {
+		p := filepath.Join(tmp, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("./ppc", "review", "--profile", filepath.Join(tmp, "team.yml"))
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Revise 2 times.") {
+		t.Errorf("expected the profile's revisions to select policies/revisions, got:\n%s", out)
+	}
+
+	cmd = exec.Command("./ppc", "review", "--profile", filepath.Join(tmp, "team.yml"), "--revisions", "3")
+	cmd.Dir = ".."
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Revise 3 times.") || strings.Count(string(out), "Revise") != 1 {
+		t.Errorf("expected --revisions to override the profile once, got:\n%s", out)
+	}
+}
+
+func TestModeSubcommandsFromExampleProfile(t *testing.T) {
+	cmd := exec.Command("./ppc", "kickoff", "--profile", "examples/06-client-meeting-coordination/profiles/kickoff-meeting.yml")
+	cmd.Dir = ".."
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out.String())
+	}
+	s := out.String()
+	if !strings.Contains(s, "This is a project kickoff meeting.") {
+		t.Errorf("expected kickoff mode output, got:\n%s", s)
+	}
+	if !strings.Contains(s, "# Kickoff Meeting: SaaS Dashboard Project") {
+		t.Errorf("expected meeting-notes contract output, got:\n%s", s)
+	}
+}
+
+func TestProfilesShowFlagsAfterName(t *testing.T) {
+	cmd := exec.Command("./ppc", "profiles", "show", "ship", "--prompts", "prompts")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "profiles/ship.yml") || !strings.Contains(string(out), "mode: ship") {
+		t.Errorf("expected the resolved ship profile, got:\n%s", out)
+	}
+}
+
+func TestTraitFlags(t *testing.T) {
+	cmd := exec.Command("./ppc", "explore", "--trait", "terse", "--traits", "traits/conservative")
+	cmd.Dir = ".."
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "Trait: Conservative") || !strings.Contains(out.String(), "Trait: Terse") {
+		t.Errorf("expected conservative and terse traits, got:\n%s", out.String())
+	}
+
+	cmd = exec.Command("./ppc", "explore", "--trait", "bogus")
+	cmd.Dir = ".."
+	out.Reset()
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected unknown trait to fail")
+	}
+	if !strings.Contains(out.String(), "available: conservative, creative, terse, verbose") {
+		t.Errorf("expected available traits listing, got:\n%s", out.String())
+	}
+}
+
+func TestCompileSelect(t *testing.T) {
+	cmd := exec.Command("./ppc", "compile", "--select", "policies/self_score")
+	cmd.Dir = ".."
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out.String())
+	}
+
+	s := out.String()
+	if !strings.HasPrefix(s, "## Self-Evaluation") {
+		t.Errorf("expected only policies/self_score, got:\n%s", s)
+	}
+	if strings.Contains(s, "Agent Identity") {
+		t.Errorf("base should not be injected without --base, got:\n%s", s)
+	}
+}
+
+func TestLayeredPromptRoots(t *testing.T) {
+	team := filepath.Join(t.TempDir(), "team")
+	writeModule := func(rel, content string) {
+		p := filepath.Join(team, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeModule("contracts/markdown.md", "---\nid: contracts/markdown\noverrides: contracts/markdown\n---\nTeam markdown contract.\n")
+
+	cmd := exec.Command("./ppc", "explore", "--prompts", "prompts", "--prompts", team, "--explain")
+	cmd.Dir = ".."
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, errOut.String())
+	}
+	if !strings.Contains(out.String(), "Team markdown contract.") {
+		t.Errorf("expected team override in output, got:\n%s", out.String())
+	}
+	if !strings.Contains(errOut.String(), "contracts/markdown: "+filepath.ToSlash(team)) {
+		t.Errorf("expected explain to show override root, got:\n%s", errOut.String())
+	}
+
+	writeModule("contracts/markdown.md", "---\nid: contracts/markdown\n---\nShadowing contract.\n")
+	cmd = exec.Command("./ppc", "explore", "--prompts", "prompts", "--prompts", team)
+	cmd.Dir = ".."
+	msg, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected shadowing without overrides to fail, got:\n%s", msg)
+	}
+	if !strings.Contains(string(msg), "declare overrides: contracts/markdown") {
+		t.Errorf("unexpected error output:\n%s", msg)
+	}
+}
+
+func TestVendorSubcommand(t *testing.T) {
+	tmp := t.TempDir()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct{ name, content string }{
+		{"guardrails/shared.md", "---\nid: guardrails/shared\n---\nShared guardrail.\n"},
+		{"README.md", "# Shared guardrails\n"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.content))
+	}
+	tw.Close()
+	if err := os.WriteFile(filepath.Join(tmp, "shared.tar"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts := filepath.Join(tmp, "prompts")
+	if err := os.MkdirAll(prompts, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(prompts, "rules.yml"), []byte("exclusive_groups: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "packages:\n  - name: shared\n    tarball: ../shared.tar\n"
+	if err := os.WriteFile(filepath.Join(prompts, "vendor.yml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("./ppc", "vendor", "--prompts", prompts)
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("vendor failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "vendored shared") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	lock, err := os.ReadFile(filepath.Join(prompts, "ppc.lock"))
+	if err != nil {
+		t.Fatalf("ppc.lock not written: %v", err)
+	}
+	if !strings.Contains(string(lock), "guardrails/shared.md:") {
+		t.Errorf("ppc.lock missing file hash:\n%s", lock)
+	}
+	if _, err := os.Stat(filepath.Join(prompts, "vendor/shared/guardrails/shared.md")); err != nil {
+		t.Errorf("module not vendored: %v", err)
+	}
+
+	cmd = exec.Command("./ppc", "compile", "--prompts", prompts, "--select", "guardrails/shared")
+	cmd.Dir = ".."
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "Shared guardrail.") {
+		t.Errorf("compiling the vendored package failed: %v\n%s", err, out)
+	}
+}
+
+func TestWatchRecompiles(t *testing.T) {
+	tmp := t.TempDir()
+	prompts := filepath.Join(tmp, "prompts")
+	if err := exec.Command("cp", "-r", "../prompts", prompts).Run(); err != nil {
+		t.Fatal(err)
+	}
+	outPath := filepath.Join(tmp, "AGENTS.md")
+
+	cmd := exec.Command("./ppc", "build", "--prompts", prompts, "--out", outPath, "--watch")
+	cmd.Dir = ".."
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if b, err := os.ReadFile(outPath); err == nil && strings.Contains(string(b), want) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("%s never contained %q", outPath, want)
+	}
+
+	waitFor("Agent Identity")
+	base := filepath.Join(prompts, "base.md")
+	b, err := os.ReadFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base, append(b, "\nWatch marker.\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("Watch marker.")
+}
+
+func TestDiffSubcommand(t *testing.T) {
+	cmd := exec.Command("./ppc", "diff", "--json", "build --conservative", "build --conservative --terse")
+	cmd.Dir = ".."
+	out, err := cmd.Output()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1 for differing sides, got %v\n%s", err, out)
+	}
+
+	var report struct {
+		Changed bool `json:"changed"`
+		Modules struct {
+			Added []string `json:"added"`
+		} `json:"modules"`
+		Diff string `json:"diff"`
+	}
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if !report.Changed || len(report.Modules.Added) != 1 || report.Modules.Added[0] != "traits/terse" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if !strings.Contains(report.Diff, "+## Trait: Terse") {
+		t.Errorf("diff missing added trait:\n%s", report.Diff)
+	}
+
+	cmd = exec.Command("./ppc", "diff", "build --terse", "build --trait terse")
+	cmd.Dir = ".."
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "no differences") {
+		t.Errorf("expected identical sides to exit 0 with no differences, got %v\n%s", err, out)
+	}
+}
+
+func TestProvenanceQuoting(t *testing.T) {
+	prompts := filepath.Join(t.TempDir(), "odd #dir -->", "prompts")
+	for rel, content := range map[string]string{
+		"rules.yml":             "exclusive_groups: []\n",
+		"guardrails/tdd: #1.md": "---\nid: guardrails/tdd\n---\nTDD.\n",
+		"guardrails/-lead.md":   "---\nid: \"guardrails/-lead\"\n---\nLead.\n",
+	} {
+		p := filepath.Join(prompts, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("./ppc", "compile", "--prompts", prompts, "--select", "guardrails/tdd,guardrails/-lead", "--provenance")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	s := string(out)
+	end := strings.Index(s, "\n-->\n")
+	if !strings.HasPrefix(s, "<!--\n") || end == -1 {
+		t.Fatalf("expected a compiled-from comment, got:\n%s", s)
+	}
+	if strings.Contains(s[4:end], "--") {
+		t.Errorf("comment body contains --:\n%s", s[:end])
+	}
+
+	var header struct {
+		CompiledFrom struct {
+			Modules []struct {
+				ID   string `yaml:"id"`
+				Path string `yaml:"path"`
+			} `yaml:"modules"`
+		} `yaml:"compiled-from"`
+	}
+	if err := yaml.Unmarshal([]byte(s[5:end]), &header); err != nil {
+		t.Fatalf("compiled-from is not valid YAML: %v\n%s", err, s[:end])
+	}
+	mods := header.CompiledFrom.Modules
+	if len(mods) != 2 {
+		t.Fatalf("modules = %+v, want 2", mods)
+	}
+	for _, m := range mods {
+		if !strings.HasPrefix(m.Path, prompts) {
+			t.Errorf("%s path = %q, want under %q", m.ID, m.Path, prompts)
+		}
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	cmd := exec.Command("./ppc", "build", "--terse", "--format", "json")
+	cmd.Dir = ".."
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	var doc struct {
+		Segments []struct {
+			ID    string `json:"id"`
+			Layer string `json:"layer"`
+			Body  string `json:"body"`
+		} `json:"segments"`
+		Meta struct {
+			Order []string `json:"order"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(doc.Segments) != len(doc.Meta.Order) {
+		t.Fatalf("got %d segments for order %v", len(doc.Segments), doc.Meta.Order)
+	}
+	for i, seg := range doc.Segments {
+		if seg.ID != doc.Meta.Order[i] {
+			t.Errorf("segment %d = %s, want %s", i, seg.ID, doc.Meta.Order[i])
+		}
+	}
+	if doc.Segments[2].Layer != "traits" || !strings.HasPrefix(doc.Segments[2].Body, "## Trait: Terse") {
+		t.Errorf("unexpected terse segment: %+v", doc.Segments[2])
+	}
+}
+
+func TestMaxTokens(t *testing.T) {
+	cmd := exec.Command("./ppc", "build", "--max-tokens", "10")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected failure over the token budget, got:\n%s", out)
+	}
+	if !strings.Contains(string(out), "over the max_tokens budget of 10") {
+		t.Errorf("unexpected error output:\n%s", out)
+	}
+
+	cmd = exec.Command("./ppc", "build", "--explain", "--max-tokens", "100000")
+	cmd.Dir = ".."
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Tokens (") || !strings.Contains(stderr.String(), "  total: ") {
+		t.Errorf("explain output lacks token counts:\n%s", stderr.String())
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	cmd := exec.Command("./ppc", "explore", "--policies", "spec_context")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected spec_context to be rejected in explore mode, got:\n%s", out)
+	}
+	if !strings.Contains(string(out), "applies_to modes: build, ship; compiling modes: explore") {
+		t.Errorf("unexpected error output:\n%s", out)
+	}
+}
+
+func TestStrictVars(t *testing.T) {
+	cmd := exec.Command("./ppc", "build", "--policies", "spec_context", "--var", "spec_name=001", "--strict-vars")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected --strict-vars to fail on unresolved variables, got:\n%s", out)
+	}
+	if !strings.Contains(string(out), "unresolved variables (--strict-vars): worktree_path (policies/spec_context, prompts/policies/spec_context.md:") {
+		t.Errorf("unexpected error output:\n%s", out)
+	}
+
+	cmd = exec.Command("./ppc", "build", "--policies", "spec_context", "--var", "spec_name=001")
+	cmd.Dir = ".."
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "warning: unresolved variable: git_root (policies/spec_context, prompts/policies/spec_context.md:") {
+		t.Errorf("expected located warnings, got:\n%s", stderr.String())
+	}
+}
+
+func TestSourcemap(t *testing.T) {
+	dir := t.TempDir()
+	mapPath := filepath.Join(dir, "out.map.json")
+	cmd := exec.Command("./ppc", "build", "--hash", "--policies", "spec_context",
+		"--var", "spec_name=001", "--sourcemap", mapPath)
+	cmd.Dir = ".."
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	b, err := os.ReadFile(mapPath)
+	if err != nil {
+		t.Fatalf("source map not written: %v", err)
+	}
+	var m struct {
+		Mappings []struct {
+			Start       int    `json:"start"`
+			End         int    `json:"end"`
+			Module      string `json:"module"`
+			Path        string `json:"path"`
+			SourceLine  int    `json:"source_line"`
+			Substituted bool   `json:"substituted"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("invalid source map: %v\n%s", err, b)
+	}
+
+	lines := strings.Split(string(out), "\n")
+	substituted := false
+	for _, mp := range m.Mappings {
+		src, err := os.ReadFile(filepath.Join("..", mp.Path))
+		if err != nil {
+			t.Fatalf("mapping %+v: %v", mp, err)
+		}
+		srcLines := strings.Split(string(src), "\n")
+		if mp.Substituted {
+			substituted = true
+			if !strings.Contains(srcLines[mp.SourceLine-1], "{{") {
+				t.Errorf("substituted mapping %+v points at %q", mp, srcLines[mp.SourceLine-1])
+			}
+			continue
+		}
+		for i := 0; i <= mp.End-mp.Start; i++ {
+			if got, want := lines[mp.Start-1+i], srcLines[mp.SourceLine-1+i]; got != want {
+				t.Errorf("%s: output line %d = %q, source line %d = %q", mp.Module, mp.Start+i, got, mp.SourceLine+i, want)
+			}
+		}
+	}
+	if !substituted {
+		t.Error("expected a substituted mapping for {{spec_name}}")
+	}
+}
+
+func TestLiteralBraces(t *testing.T) {
+	prompts := filepath.Join(t.TempDir(), "prompts")
+	if err := exec.Command("cp", "-r", "../prompts", prompts).Run(); err != nil {
+		t.Fatal(err)
+	}
+	module := "---\nid: policies/ci\ndesc: CI templating notes.\n---\n\n" +
+		"Reference the commit as \\{{ github.sha }}.\n\n" +
+		"{{raw}}\nname: {{ matrix.os }}\n{{/raw}}\n\n" +
+		"```yaml\nruns-on: {{ runner }}\n```\n"
+	if err := os.WriteFile(filepath.Join(prompts, "policies/ci.md"), []byte(module), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	build := func() (string, error) {
+		cmd := exec.Command("./ppc", "build", "--prompts", prompts, "--policies", "ci", "--strict-vars")
+		cmd.Dir = ".."
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	out, err := build()
+	if err == nil || !strings.Contains(out, "unresolved variables (--strict-vars): runner") {
+		t.Fatalf("expected the fenced placeholder to be substituted by default, got %v:\n%s", err, out)
+	}
+
+	rules := filepath.Join(prompts, "rules.yml")
+	b, err := os.ReadFile(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rules, append(b, "substitution:\n  skip_code_blocks: true\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = build()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{"as {{ github.sha }}.", "name: {{ matrix.os }}", "runs-on: {{ runner }}"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "{{raw}}") || strings.Contains(out, "{{/raw}}") {
+		t.Errorf("raw markers leaked into output:\n%s", out)
+	}
+}
+
+func TestVarsSources(t *testing.T) {
+	dir := t.TempDir()
+	yml := filepath.Join(dir, "vars.yml")
+	env := filepath.Join(dir, "ci.env")
+	if err := os.WriteFile(yml, []byte("spec_name: \"000\"\ngit_root: /src\nwindow_name: main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(env, []byte("GIT_ROOT=/ci/src\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("./ppc", "build", "--policies", "spec_context", "--format", "json",
+		"--vars", yml, "--vars", env, "--vars-env", "PPCTEST_", "--var", "spec_name=001")
+	cmd.Dir = ".."
+	cmd.Env = append(os.Environ(), "PPCTEST_WORKTREE_PATH=/wt")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+
+	var doc struct {
+		Segments []struct{ ID, Body string }
+		Meta     struct {
+			VarSources []struct{ Name, Source string } `json:"var_sources"`
+		}
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	got := map[string]string{}
+	for _, v := range doc.Meta.VarSources {
+		got[v.Name] = v.Source
+	}
+	want := map[string]string{
+		"spec_name":     "var",
+		"git_root":      "file:" + env,
+		"window_name":   "file:" + yml,
+		"worktree_path": "env:PPCTEST_WORKTREE_PATH",
+	}
+	for name, source := range want {
+		if got[name] != source {
+			t.Errorf("source of %s = %q, want %q (all: %v)", name, got[name], source, got)
+		}
+	}
+	for _, s := range doc.Segments {
+		if s.ID == "policies/spec_context" && !strings.Contains(s.Body, "Git root: `/ci/src`") {
+			t.Errorf("later --vars file did not override:\n%s", s.Body)
+		}
+	}
+}
+
+func TestProfileExtendsAllGuardrails(t *testing.T) {
+	dir := t.TempDir()
+	profiles := map[string]string{
+		"strict.yml": "mode: build\ncontract: code\nrevisions: 2\nguardrails: [all]\n",
+		"child.yml":  "extends: strict\nguardrails: [tdd]\n",
+	}
+	for name, content := range profiles {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("./ppc", "build", "--profile", filepath.Join(dir, "child.yml"), "--revisions", "3", "--format", "json")
+	cmd.Dir = ".."
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	var doc struct {
+		Meta struct {
+			SelectedIDs []string `json:"selected_ids"`
+		}
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	count := map[string]int{}
+	for _, id := range doc.Meta.SelectedIDs {
+		count[id]++
+	}
+	for _, id := range []string{"guardrails/forbidden_patterns", "guardrails/snake_case", "guardrails/tdd", "guardrails/unsafe_commands", "policies/revisions"} {
+		if count[id] != 1 {
+			t.Errorf("%s selected %d times, want once (selected: %v)", id, count[id], doc.Meta.SelectedIDs)
+		}
+	}
+}
+
+func TestDiffQuotedSides(t *testing.T) {
+	cmd := exec.Command("./ppc", "diff", "--json",
+		"build --policies spec_context --var spec_name='Spec one'",
+		`build --policies spec_context --var "spec_name=Spec \"two\""`)
+	cmd.Dir = ".."
+	out, _ := cmd.Output()
+	var report struct {
+		Left struct{ Label string }
+		Diff string `json:"diff"`
+	}
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	for _, want := range []string{"-## Spec: Spec one", `+## Spec: Spec "two"`} {
+		if !strings.Contains(report.Diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, report.Diff)
+		}
+	}
+	if report.Left.Label != `build --policies spec_context --var "spec_name=Spec one"` {
+		t.Errorf("left label = %q", report.Left.Label)
+	}
+
+	cmd = exec.Command("./ppc", "diff", "build --var 'open")
+	cmd.Dir = ".."
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "unterminated quote") {
+		t.Errorf("expected unterminated quote error, got %v\n%s", err, out)
+	}
+}
+
+func TestDiffAtRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	ppc, err := filepath.Abs("../ppc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := t.TempDir()
+	for _, dir := range []string{"prompts", "profiles"} {
+		if err := exec.Command("cp", "-r", filepath.Join("..", dir), filepath.Join(repo, dir)).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repo, "vars.yml"), []byte("spec_name: \"001\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "v1")
+
+	base := filepath.Join(repo, "prompts/base.md")
+	b, err := os.ReadFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base, append(b, "\nWorking tree marker.\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "vars.yml"), []byte("spec_name: \"002\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(ppc, "diff", "--ref", "HEAD", "--profile", "build",
+		"build --policies spec_context --vars vars.yml")
+	cmd.Dir = repo
+	out, err := cmd.Output()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %v\n%s", err, out)
+	}
+	for _, want := range []string{"+Working tree marker.", "-## Spec: 001", "+## Spec: 002"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("diff missing %q:\n%s", want, out)
+		}
+	}
+}